@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Attempt — одна попытка прохождения теста со всеми ответами
+type Attempt struct {
+	ID         string
+	TestName   string
+	UserID     int64
+	Username   string
+	Score      float64
+	MaxScore   float64
+	Passed     bool
+	FinishedAt time.Time
+	Duration   time.Duration
+	// Interrupted — попытка прервана: пользователь ее бросил, она истекла или он начал другой тест.
+	// Такая попытка расходует попытку, но не идет в результаты теста.
+	Interrupted bool
+	Answers     []AttemptAnswer
+}
+
+// AttemptAnswer — ответ на один вопрос попытки. Текст вопроса сохраняется вместе с ответом,
+// чтобы история оставалась понятной, даже если вопрос потом изменят.
+type AttemptAnswer struct {
+	QuestionID string
+	Question   string
+	// Answer — выбранные варианты через запятую или свободный ответ
+	Answer string
+	// Points — начисленные баллы, MaxPoints — вес вопроса
+	Points    float64
+	MaxPoints float64
+	TimedOut  bool
+}
+
+// Wrong сообщает, что вопрос не засчитан полностью. В записях без веса вопроса он считается равным одному баллу.
+func (a AttemptAnswer) Wrong() bool {
+	if a.MaxPoints <= 0 {
+		return a.Points < 1
+	}
+	return a.Points < a.MaxPoints
+}
+
+// WrongAnswers возвращает ответы попытки, засчитанные не полностью
+func (a Attempt) WrongAnswers() []AttemptAnswer {
+	var wrong []AttemptAnswer
+	for _, answer := range a.Answers {
+		if answer.Wrong() {
+			wrong = append(wrong, answer)
+		}
+	}
+	return wrong
+}
+
+// Result возвращает результат попытки для таблицы результатов теста
+func (a Attempt) Result() TestResult {
+	return TestResult{
+		TestName:   a.TestName,
+		UserID:     a.UserID,
+		Username:   a.Username,
+		Score:      a.Score,
+		MaxScore:   a.MaxScore,
+		Passed:     a.Passed,
+		FinishedAt: a.FinishedAt,
+		Duration:   a.Duration,
+	}
+}
+
+// AttemptQuery отбирает попытки одного пользователя: по ID или, если ID не задан, по имени.
+// TestName ограничивает попытки одним тестом, Limit — числом самых свежих попыток (0 — все).
+// Пустой запрос возвращает все попытки.
+type AttemptQuery struct {
+	UserID   int64
+	Username string
+	TestName string
+	Limit    int
+}
+
+// matches сообщает, что попытка подходит под запрос
+func (q AttemptQuery) matches(attempt Attempt) bool {
+	if q.TestName != "" && attempt.TestName != q.TestName {
+		return false
+	}
+	switch {
+	case q.UserID != 0:
+		return attempt.UserID == q.UserID
+	case q.Username != "":
+		return strings.EqualFold(strings.TrimPrefix(attempt.Username, "@"), strings.TrimPrefix(q.Username, "@"))
+	default:
+		return true
+	}
+}
+
+// selectAttempts отбирает подходящие попытки, от новых к старым, с учетом Limit
+func selectAttempts(attempts []Attempt, query AttemptQuery) []Attempt {
+	var selected []Attempt
+	for _, attempt := range attempts {
+		if query.matches(attempt) {
+			selected = append(selected, attempt)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].FinishedAt.After(selected[j].FinishedAt)
+	})
+	if query.Limit > 0 && len(selected) > query.Limit {
+		selected = selected[:query.Limit]
+	}
+	return selected
+}
+
+// attemptResults возвращает результаты завершенных попыток; прерванные пропускаются
+func attemptResults(attempts []Attempt) []TestResult {
+	results := make([]TestResult, 0, len(attempts))
+	for _, attempt := range attempts {
+		if !attempt.Interrupted {
+			results = append(results, attempt.Result())
+		}
+	}
+	return results
+}
+
+// withAttempt добавляет попытку в начало истории, если ее там еще нет
+// (например, если запись в историю не удалась)
+func withAttempt(history []Attempt, attempt Attempt) []Attempt {
+	for _, previous := range history {
+		if previous.ID == attempt.ID {
+			return history
+		}
+	}
+	return append([]Attempt{attempt}, history...)
+}
+
+// retakeRefusal проверяет правила пересдачи теста: attempts — попытки пользователя
+// в этом тесте (включая прерванные), от новых к старым. Возвращает причину отказа или пустую строку, если начать можно.
+func retakeRefusal(settings TestSettings, attempts []Attempt, now time.Time) string {
+	if settings.MaxAttempts > 0 && len(attempts) >= settings.MaxAttempts {
+		return fmt.Sprintf("Вы использовали все попытки этого теста (%d).", settings.MaxAttempts)
+	}
+	if settings.RetakeCooldown > 0 && len(attempts) > 0 {
+		next := attempts[0].FinishedAt.Add(settings.RetakeCooldown)
+		if now.Before(next) {
+			return fmt.Sprintf("Следующая попытка будет доступна через %s.", formatDuration(next.Sub(now).Round(time.Second)))
+		}
+	}
+	return ""
+}
+
+// newAttempt собирает попытку из завершенной сессии. Вопросы, до которых пользователь не дошел,
+// записываются как неотвеченные.
+func newAttempt(session *Session, finishedAt time.Time) Attempt {
+	attempt := Attempt{
+		ID:         session.AttemptID,
+		TestName:   session.TestName,
+		UserID:     session.UserID,
+		Username:   session.Username,
+		Score:      session.FinalScore(),
+		MaxScore:   session.MaxScore(),
+		Passed:     session.Settings.Passed(session.FinalScore(), session.MaxScore()),
+		FinishedAt: finishedAt,
+		Duration:   session.Elapsed(finishedAt),
+	}
+	if attempt.ID == "" {
+		attempt.ID = newAttemptID()
+	}
+
+	for i, question := range session.Questions {
+		record := AnswerRecord{QuestionID: question.ID, TimedOut: true}
+		if i < len(session.Answers) {
+			record = session.Answers[i]
+		}
+		answer := AttemptAnswer{
+			QuestionID: question.ID,
+			Question:   question.Question,
+			Points:     record.Points,
+			MaxPoints:  question.Weight(),
+			TimedOut:   record.TimedOut,
+		}
+		if !record.TimedOut {
+			answer.Answer = givenAnswerText(question, record)
+		}
+		attempt.Answers = append(attempt.Answers, answer)
+	}
+	return attempt
+}
+
+// interruptedAttempt собирает прерванную попытку из незавершенной сессии: ответы до момента at
+// сохраняются, а зачет не ставится
+func interruptedAttempt(session *Session, at time.Time) Attempt {
+	attempt := newAttempt(session, at)
+	attempt.Interrupted = true
+	attempt.Passed = false
+	return attempt
+}
+
+// attemptSummary — строка истории: тест, балл и процент, дата, время прохождения и число ошибок
+func attemptSummary(attempt Attempt) string {
+	line := fmt.Sprintf("%s — %s (%s%%), %s", attempt.TestName, formatScore(attempt.Score, attempt.MaxScore),
+		formatPoints(attempt.Result().Percent()), attempt.FinishedAt.Format("02.01.2006 15:04"))
+	if attempt.Duration > 0 {
+		line += ", " + formatDuration(attempt.Duration)
+	}
+	if wrong := len(attempt.WrongAnswers()); wrong > 0 {
+		line += fmt.Sprintf(", ошибок: %d", wrong)
+	}
+	if attempt.Interrupted {
+		line += " (прервана)"
+	}
+	return line
+}
+
+// wrongAnswersMessages перечисляет для преподавателя ошибки ученика в каждой попытке.
+// Текст делится на несколько сообщений, если не помещается в одно.
+func wrongAnswersMessages(student string, attempts []Attempt) []string {
+	var blocks []string
+	for _, attempt := range attempts {
+		block := attemptSummary(attempt)
+		for _, answer := range attempt.WrongAnswers() {
+			given := answer.Answer
+			if answer.TimedOut {
+				given = "нет ответа"
+			}
+			block += fmt.Sprintf("\n❌ %s. %s\nОтвет: %s", answer.QuestionID, answer.Question, given)
+			if answer.Points != 0 {
+				block += fmt.Sprintf(" (%s балла)", formatPoints(answer.Points))
+			}
+		}
+		blocks = append(blocks, block)
+	}
+	return packMessages(fmt.Sprintf("📋 Попытки ученика %s:", student), blocks)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetakeRefusal(t *testing.T) {
+	now := time.Date(2026, 10, 2, 12, 0, 0, 0, time.Local)
+	// Попытки от новых к старым: последняя — час назад
+	attempts := []Attempt{
+		{ID: "a2", FinishedAt: now.Add(-time.Hour)},
+		{ID: "a1", FinishedAt: now.Add(-48 * time.Hour), Interrupted: true},
+	}
+	tests := []struct {
+		name     string
+		settings TestSettings
+		attempts []Attempt
+		want     string
+	}{
+		{name: "без ограничений", attempts: attempts},
+		{name: "первая попытка при лимите", settings: TestSettings{MaxAttempts: 1}},
+		{name: "лимит не исчерпан", settings: TestSettings{MaxAttempts: 3}, attempts: attempts},
+		// Прерванная попытка тоже расходует попытку
+		{name: "лимит исчерпан", settings: TestSettings{MaxAttempts: 2}, attempts: attempts, want: "Вы использовали все попытки этого теста (2)."},
+		{name: "перерыв прошел", settings: TestSettings{RetakeCooldown: time.Hour}, attempts: attempts},
+		{name: "перерыв не прошел", settings: TestSettings{RetakeCooldown: 3 * time.Hour}, attempts: attempts, want: "Следующая попытка будет доступна через 2h0m0s."},
+		{name: "перерыв без попыток", settings: TestSettings{RetakeCooldown: 3 * time.Hour}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retakeRefusal(tt.settings, tt.attempts, now); got != tt.want {
+				t.Errorf("retakeRefusal = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttemptResultsSkipsInterrupted(t *testing.T) {
+	attempts := []Attempt{
+		{ID: "a3", Score: 1, MaxScore: 2},
+		{ID: "a2", Score: 0, MaxScore: 2, Interrupted: true},
+		{ID: "a1", Score: 2, MaxScore: 2},
+	}
+	results := attemptResults(attempts)
+	if len(results) != 2 || results[0].Score != 1 || results[1].Score != 2 {
+		t.Errorf("attemptResults = %+v", results)
+	}
+}
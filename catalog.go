@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TestInfo — описание теста из вкладки Tests: как тест называется и когда его показывать ученикам
+type TestInfo struct {
+	// Name — название вкладки теста
+	Name        string
+	Title       string
+	Description string
+	Category    string
+	// Order — место теста в списке: меньше — выше; 0 — не задано (такие тесты идут после остальных)
+	Order int
+	// Visible — показывать ли тест ученикам; тесты, которых нет во вкладке Tests, считаются черновиками
+	Visible bool
+	// OpensAt и ClosesAt — срок, когда тест можно пройти (нулевое время — без ограничения)
+	OpensAt  time.Time
+	ClosesAt time.Time
+}
+
+// DisplayName возвращает название теста для учеников: Title или, если он не задан, название вкладки
+func (t TestInfo) DisplayName() string {
+	if t.Title != "" {
+		return t.Title
+	}
+	return t.Name
+}
+
+// Open сообщает, что тест виден ученикам и открыт в момент now
+func (t TestInfo) Open(now time.Time) bool {
+	return t.Visible && !t.NotOpenYet(now) && !t.Closed(now)
+}
+
+// NotOpenYet сообщает, что срок теста еще не начался
+func (t TestInfo) NotOpenYet(now time.Time) bool {
+	return !t.OpensAt.IsZero() && now.Before(t.OpensAt)
+}
+
+// Closed сообщает, что срок теста закончился
+func (t TestInfo) Closed(now time.Time) bool {
+	return !t.ClosesAt.IsZero() && !now.Before(t.ClosesAt)
+}
+
+// TestCatalog — все тесты хранилища с описаниями, в порядке показа
+type TestCatalog struct {
+	Tests []TestInfo
+	// Problems — ошибки в ячейках вкладки Tests: строки с ошибками не применены
+	Problems []error
+}
+
+// Find возвращает описание теста по названию вкладки
+func (c TestCatalog) Find(name string) (TestInfo, bool) {
+	for _, info := range c.Tests {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	return TestInfo{}, false
+}
+
+// Listed возвращает тесты, которые показываются ученикам в момент now: видимые и еще не закрытые
+// (тест, срок которого не начался, показывается без кнопки)
+func (c TestCatalog) Listed(now time.Time) []TestInfo {
+	var listed []TestInfo
+	for _, info := range c.Tests {
+		if info.Visible && !info.Closed(now) {
+			listed = append(listed, info)
+		}
+	}
+	return listed
+}
+
+// catalogHeader — заголовки вкладки Tests; строки описаний начинаются со второй строки
+var catalogHeader = []interface{}{"Вкладка", "Название", "Описание", "Категория", "Порядок", "Показывать", "Доступен с", "Доступен до"}
+
+// catalogRange возвращает диапазон описаний тестов во вкладке Tests
+func catalogRange() a1Range {
+	return a1Range{StartColumn: "A", StartRow: 2, EndColumn: columnLetter(len(catalogHeader))}
+}
+
+// buildCatalog собирает каталог тестов names (вкладки в порядке таблицы) по строкам вкладки Tests.
+// Если вкладка Tests пуста, все тесты видны и идут в порядке вкладок, как раньше.
+// Иначе ученикам видны только описанные в ней тесты, а остальные вкладки считаются черновиками.
+func buildCatalog(names []string, table sheetTable) TestCatalog {
+	var catalog TestCatalog
+	exists := make(map[string]bool, len(names))
+	for _, name := range names {
+		exists[name] = true
+	}
+
+	described := make(map[string]bool)
+	drafts := false
+	for i := range table.Rows {
+		d := table.decoder(i)
+		if d.Empty() {
+			continue
+		}
+		drafts = true
+		info, ok := decodeTestInfo(d)
+		switch {
+		case !ok:
+		case !exists[info.Name]:
+			d.Fail(0, "вкладка теста %q не найдена", info.Name)
+		case described[info.Name]:
+			d.Fail(0, "тест %q уже описан выше", info.Name)
+		default:
+			described[info.Name] = true
+			catalog.Tests = append(catalog.Tests, info)
+		}
+		catalog.Problems = append(catalog.Problems, d.Errors()...)
+	}
+
+	// Вкладки без описания: если вкладка Tests пуста, тест виден, иначе это черновик
+	for _, name := range names {
+		if !described[name] {
+			catalog.Tests = append(catalog.Tests, TestInfo{Name: name, Visible: !drafts})
+		}
+	}
+
+	sort.SliceStable(catalog.Tests, func(i, j int) bool {
+		a, b := catalog.Tests[i].Order, catalog.Tests[j].Order
+		if a == 0 || b == 0 {
+			return a != 0 && b == 0
+		}
+		return a < b
+	})
+	return catalog
+}
+
+// decodeTestInfo разбирает строку вкладки Tests
+func decodeTestInfo(d *rowDecoder) (TestInfo, bool) {
+	info := TestInfo{
+		Name:        strings.TrimSpace(d.RequiredText(0, "Вкладка")),
+		Title:       strings.TrimSpace(d.Text(1)),
+		Description: strings.TrimSpace(d.Text(2)),
+		Category:    strings.TrimSpace(d.Text(3)),
+		Order:       d.Int(4, "Порядок"),
+		Visible:     d.Flag(5, "Показывать", true),
+		OpensAt:     d.Time(6, "Доступен с"),
+		ClosesAt:    d.Time(7, "Доступен до"),
+	}
+	// Дата без времени в "Доступен до" включает весь этот день
+	if !info.ClosesAt.IsZero() && isMidnight(info.ClosesAt) {
+		info.ClosesAt = info.ClosesAt.AddDate(0, 0, 1)
+	}
+	if !info.OpensAt.IsZero() && !info.ClosesAt.IsZero() && !info.OpensAt.Before(info.ClosesAt) {
+		d.Fail(7, "тест закрывается раньше, чем открывается")
+	}
+	return info, d.OK()
+}
+
+// testListText формирует текст экрана "Тесты": тесты по категориям с описаниями и сроками.
+// Если текст не помещается в сообщение, остается только заголовок — тесты видны на кнопках.
+func testListText(tests []TestInfo, now time.Time) string {
+	const title = "✅ Доступные тесты:"
+	var b strings.Builder
+	b.WriteString(title)
+
+	for _, category := range groupByCategory(tests) {
+		if category.Name != "" {
+			fmt.Fprintf(&b, "\n\n📂 %s", category.Name)
+		} else {
+			b.WriteString("\n")
+		}
+		for _, info := range category.Tests {
+			fmt.Fprintf(&b, "\n• %s", info.DisplayName())
+			switch {
+			case info.NotOpenYet(now):
+				fmt.Fprintf(&b, " — откроется %s", formatTestDate(info.OpensAt))
+			case !info.ClosesAt.IsZero() && isMidnight(info.ClosesAt):
+				fmt.Fprintf(&b, " — до %s включительно", formatTestDate(info.ClosesAt.AddDate(0, 0, -1)))
+			case !info.ClosesAt.IsZero():
+				fmt.Fprintf(&b, " — до %s", formatTestDate(info.ClosesAt))
+			}
+			if info.Description != "" {
+				fmt.Fprintf(&b, "\n  %s", info.Description)
+			}
+		}
+	}
+	if b.Len() > maxMessageLength {
+		return title
+	}
+	return b.String()
+}
+
+// isMidnight сообщает, что время приходится на начало суток (в ячейке указана только дата)
+func isMidnight(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0
+}
+
+// formatTestDate форматирует срок теста; время не указывается, если срок приходится на начало суток
+func formatTestDate(t time.Time) string {
+	if isMidnight(t) {
+		return t.Format("02.01.2006")
+	}
+	return t.Format("02.01.2006 15:04")
+}
+
+// testCategory — тесты одной категории в порядке показа
+type testCategory struct {
+	Name  string
+	Tests []TestInfo
+}
+
+// groupByCategory группирует тесты по категориям. Категории идут в порядке первого теста,
+// тесты без категории — отдельной группой на месте первого из них.
+func groupByCategory(tests []TestInfo) []testCategory {
+	var categories []testCategory
+	index := make(map[string]int)
+	for _, info := range tests {
+		i, ok := index[info.Category]
+		if !ok {
+			i = len(categories)
+			index[info.Category] = i
+			categories = append(categories, testCategory{Name: info.Category})
+		}
+		categories[i].Tests = append(categories[i].Tests, info)
+	}
+	return categories
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBuildCatalog(t *testing.T) {
+	names := []string{"Алгебра", "Геометрия", "Физика", "Черновик"}
+	tests := []struct {
+		name      string
+		rows      [][]interface{}
+		wantOrder []string
+		// wantVisible — тесты, которые видны ученикам
+		wantVisible []string
+		wantCells   []string
+	}{
+		{
+			name:        "без вкладки Tests",
+			wantOrder:   names,
+			wantVisible: names,
+		},
+		{
+			// Неописанные вкладки — черновики в конце списка; тесты без порядка идут после упорядоченных
+			name: "порядок и черновики",
+			rows: [][]interface{}{
+				{"Физика", "", "", "", 2.0},
+				{"Алгебра"},
+				{"Геометрия", "", "", "", 1.0, "нет"},
+			},
+			wantOrder:   []string{"Геометрия", "Физика", "Алгебра", "Черновик"},
+			wantVisible: []string{"Физика", "Алгебра"},
+		},
+		{
+			name: "ошибки в строках",
+			rows: [][]interface{}{
+				{"Алгебра", "", "", "", "первый"},
+				{"Химия"},
+				{"Физика"},
+				{"Физика", "Повтор"},
+				{},
+				{"Геометрия", "", "", "", "", "", "2026-10-10", "2026-10-01"},
+			},
+			wantOrder:   []string{"Физика", "Алгебра", "Геометрия", "Черновик"},
+			wantVisible: []string{"Физика"},
+			wantCells:   []string{"E2", "A3", "A5", "H7"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalog := buildCatalog(names, sheetTable{Sheet: "Tests", Range: catalogRange(), Rows: tt.rows})
+			var order, visible []string
+			for _, info := range catalog.Tests {
+				order = append(order, info.Name)
+				if info.Visible {
+					visible = append(visible, info.Name)
+				}
+			}
+			if !slices.Equal(order, tt.wantOrder) || !slices.Equal(visible, tt.wantVisible) {
+				t.Errorf("порядок %v, видны %v; ожидалось %v, %v", order, visible, tt.wantOrder, tt.wantVisible)
+			}
+			var cells []string
+			for _, problem := range catalog.Problems {
+				var cellErr *CellError
+				if !errors.As(problem, &cellErr) {
+					t.Fatalf("ошибка без адреса ячейки: %v", problem)
+				}
+				cells = append(cells, cellErr.Cell)
+			}
+			if !slices.Equal(cells, tt.wantCells) {
+				t.Errorf("ошибки в ячейках %v, ожидались %v: %v", cells, tt.wantCells, catalog.Problems)
+			}
+		})
+	}
+}
+
+func TestTestInfoAvailability(t *testing.T) {
+	rows := [][]interface{}{
+		{"Тест", "", "", "", "", "да", "2026-10-10 09:00:00", "2026-10-20"},
+	}
+	catalog := buildCatalog([]string{"Тест"}, sheetTable{Sheet: "Tests", Range: catalogRange(), Rows: rows})
+	info, ok := catalog.Find("Тест")
+	if !ok {
+		t.Fatalf("каталог = %+v", catalog)
+	}
+	at := func(day, hour int) time.Time { return time.Date(2026, 10, day, hour, 0, 0, 0, time.Local) }
+	tests := []struct {
+		now        time.Time
+		wantOpen   bool
+		wantListed bool
+	}{
+		// До открытия тест показывается в списке, но пройти его нельзя
+		{now: at(10, 8), wantListed: true},
+		{now: at(10, 9), wantOpen: true, wantListed: true},
+		// Дата без времени в "Доступен до" включает весь день
+		{now: at(20, 23), wantOpen: true, wantListed: true},
+		{now: at(21, 0)},
+	}
+	for _, tt := range tests {
+		listed := len(catalog.Listed(tt.now)) == 1
+		if info.Open(tt.now) != tt.wantOpen || listed != tt.wantListed {
+			t.Errorf("%s: открыт %t, в списке %t; ожидалось %t, %t", tt.now, info.Open(tt.now), listed, tt.wantOpen, tt.wantListed)
+		}
+	}
+}
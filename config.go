@@ -0,0 +1,582 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config — настройки бота. Загружаются из YAML-файла, затем переопределяются переменными окружения
+// (имя переменной указано в теге env), после чего проверяются в Validate.
+type Config struct {
+	Telegram TelegramConfig `yaml:"telegram"`
+	Sheets   SheetsConfig   `yaml:"sheets"`
+	Storage  StorageConfig  `yaml:"storage"`
+	Sessions SessionsConfig `yaml:"sessions"`
+	Workers  WorkersConfig  `yaml:"workers"`
+
+	// Tenants — классы (группы) со своими таблицами. Если список пуст, бот обслуживает
+	// один класс с таблицей из секции sheets.
+	Tenants []TenantConfig `yaml:"tenants"`
+	// Admins — Telegram ID администраторов с доступом к служебным командам во всех классах
+	Admins []int64 `yaml:"admins"`
+
+	// TenantBindingsFile — файл, где хранится, к какому классу присоединился каждый ученик (/join)
+	TenantBindingsFile string `yaml:"tenant_bindings_file" env:"TENANT_BINDINGS_FILE"`
+
+	// LeaderboardRefresh — период фонового пересчета Leaderboard
+	LeaderboardRefresh time.Duration `yaml:"leaderboard_refresh" env:"LEADERBOARD_REFRESH"`
+	// ShutdownTimeout — сколько ждать завершения начатых обработчиков при остановке
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+type TelegramConfig struct {
+	Token string `yaml:"token" env:"TELEGRAM_BOT_TOKEN"`
+}
+
+// SheetsConfig описывает таблицу Google Sheets и расположение данных в ней
+type SheetsConfig struct {
+	SpreadsheetID   string `yaml:"spreadsheet_id" env:"SPREADSHEET_ID"`
+	CredentialsFile string `yaml:"credentials_file" env:"GOOGLE_CREDENTIALS_FILE"`
+
+	LeaderboardSheet string `yaml:"leaderboard_sheet" env:"LEADERBOARD_SHEET"`
+	TeacherSheet     string `yaml:"teacher_sheet" env:"TEACHER_SHEET"`
+	// AttemptsSheet и AnswersSheet — история всех попыток и ответы на каждый вопрос в них
+	AttemptsSheet string `yaml:"attempts_sheet" env:"ATTEMPTS_SHEET"`
+	AnswersSheet  string `yaml:"answers_sheet" env:"ANSWERS_SHEET"`
+	// TestsSheet — описание тестов: название, категория, порядок, видимость и сроки (см. TestInfo)
+	TestsSheet string `yaml:"tests_sheet" env:"TESTS_SHEET"`
+
+	// Диапазоны внутри вкладки теста: вопросы (ID, вопрос, варианты, номер ответа) и результаты
+	QuestionsRange string `yaml:"questions_range" env:"QUESTIONS_RANGE"`
+	ResultsRange   string `yaml:"results_range" env:"RESULTS_RANGE"`
+	// SettingsRange — пары "параметр | значение" с настройками теста (см. TestSettings)
+	SettingsRange string `yaml:"settings_range" env:"SETTINGS_RANGE"`
+	// QuestionColumns — в каких колонках диапазона вопросов лежат части вопроса
+	QuestionColumns QuestionColumns `yaml:"question_columns"`
+
+	LeaderboardRange        string `yaml:"leaderboard_range" env:"LEADERBOARD_RANGE"`
+	TeacherInfoRange        string `yaml:"teacher_info_range" env:"TEACHER_INFO_RANGE"`
+	TeacherDescriptionRange string `yaml:"teacher_description_range" env:"TEACHER_DESCRIPTION_RANGE"`
+}
+
+// QuestionColumns задает колонки вопроса буквами, как в таблице. Options — диапазон колонок
+// вариантов ("C:E"); у вопроса может быть от двух вариантов до ширины этого диапазона.
+type QuestionColumns struct {
+	ID       string `yaml:"id"`
+	Question string `yaml:"question"`
+	Options  string `yaml:"options"`
+	Answer   string `yaml:"answer"`
+	// Type — необязательная колонка с типом вопроса (single, multi); если не задана,
+	// тип определяется по колонке ответа: "1,3" — несколько верных вариантов
+	Type string `yaml:"type"`
+	// Explanation — необязательная колонка с пояснением к правильному ответу
+	Explanation string `yaml:"explanation"`
+	// Points — необязательная колонка с весом вопроса в баллах (пусто — один балл)
+	Points string `yaml:"points"`
+	// Photo, Audio, Video — необязательные колонки с медиа вопроса: URL или file_id Telegram
+	Photo string `yaml:"photo"`
+	Audio string `yaml:"audio"`
+	Video string `yaml:"video"`
+}
+
+type StorageConfig struct {
+	// Backend — sheets или sqlite
+	Backend    string `yaml:"backend" env:"STORAGE_BACKEND"`
+	SQLitePath string `yaml:"sqlite_path" env:"SQLITE_PATH"`
+}
+
+type SessionsConfig struct {
+	// File — путь к файлу сессий; если пустой, сессии хранятся только в памяти
+	File string `yaml:"file" env:"SESSION_STORE_FILE"`
+	// AttemptTTL — через сколько времени без активности незавершенная попытка удаляется
+	// (0 — попытки не истекают)
+	AttemptTTL time.Duration `yaml:"attempt_ttl" env:"ATTEMPT_TTL"`
+}
+
+type WorkersConfig struct {
+	Count     int `yaml:"count" env:"WORKERS"`
+	QueueSize int `yaml:"queue_size" env:"UPDATE_QUEUE_SIZE"`
+}
+
+// TenantConfig описывает один класс: его таблицу (или базу SQLite), код приглашения и закрепленные чаты
+type TenantConfig struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+
+	// SpreadsheetID и SQLitePath переопределяют sheets.spreadsheet_id и storage.sqlite_path
+	SpreadsheetID string `yaml:"spreadsheet_id"`
+	SQLitePath    string `yaml:"sqlite_path"`
+
+	// InviteCode — код для команды /join <код>
+	InviteCode string `yaml:"invite_code"`
+	// Chats — ID групповых чатов, все участники которых относятся к этому классу
+	Chats []int64 `yaml:"chats"`
+	// Admins — Telegram ID преподавателей класса: им доступна история ответов учеников
+	Admins []int64 `yaml:"admins"`
+}
+
+// defaultTenantID — ID класса, который создается, если список tenants пуст
+const defaultTenantID = "default"
+
+// TenantConfigs возвращает настроенные классы или один класс по умолчанию
+func (c Config) TenantConfigs() []TenantConfig {
+	if len(c.Tenants) > 0 {
+		return c.Tenants
+	}
+	return []TenantConfig{{ID: defaultTenantID}}
+}
+
+// ForTenant возвращает копию настроек, в которой таблица и база SQLite заменены на таблицу класса
+func (c Config) ForTenant(t TenantConfig) Config {
+	if t.SpreadsheetID != "" {
+		c.Sheets.SpreadsheetID = t.SpreadsheetID
+	}
+	if t.SQLitePath != "" {
+		c.Storage.SQLitePath = t.SQLitePath
+	}
+	return c
+}
+
+// defaultConfig возвращает настройки, совпадающие с исходной раскладкой таблицы
+func defaultConfig() Config {
+	return Config{
+		Sheets: SheetsConfig{
+			// Таблица, с которой бот работал до появления файла настроек
+			SpreadsheetID:           "12d036WzCPyL97CtbiU2Vx2BQtr2JDDpVx9mBwSTmwo8",
+			CredentialsFile:         "credentials.json",
+			LeaderboardSheet:        "Leaderboard",
+			TeacherSheet:            "Teacher",
+			AttemptsSheet:           "Attempts",
+			AnswersSheet:            "Answers",
+			TestsSheet:              "Tests",
+			QuestionsRange:          "A2:F",
+			ResultsRange:            "H2:Q",
+			SettingsRange:           "S2:T",
+			LeaderboardRange:        "A2:D",
+			TeacherInfoRange:        "A2:A10",
+			TeacherDescriptionRange: "B2:B12",
+			QuestionColumns: QuestionColumns{
+				ID:       "A",
+				Question: "B",
+				Options:  "C:E",
+				Answer:   "F",
+			},
+		},
+		Storage: StorageConfig{
+			Backend:    "sheets",
+			SQLitePath: "bot.db",
+		},
+		Sessions: SessionsConfig{
+			File:       "sessions.json",
+			AttemptTTL: 24 * time.Hour,
+		},
+		Workers: WorkersConfig{
+			Count:     8,
+			QueueSize: 100,
+		},
+		TenantBindingsFile: "tenants.json",
+		LeaderboardRefresh: 5 * time.Minute,
+		ShutdownTimeout:    30 * time.Second,
+	}
+}
+
+// LoadConfig читает настройки из файла path и переменных окружения.
+// Если файла нет и он не был указан явно (required == false), используются значения по умолчанию.
+func LoadConfig(path string, required bool) (Config, error) {
+	cfg := defaultConfig()
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("не удалось разобрать файл настроек %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !required:
+	default:
+		return cfg, fmt.Errorf("не удалось прочитать файл настроек %s: %w", path, err)
+	}
+
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// applyEnv заполняет поля с тегом env значениями переменных окружения (если они заданы)
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		name := t.Field(i).Tag.Get("env")
+
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if name == "" || !ok {
+			continue
+		}
+
+		switch {
+		case field.Type() == reflect.TypeOf(time.Duration(0)):
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("некорректное значение %s=%q: %w", name, value, err)
+			}
+			field.SetInt(int64(d))
+		case field.Kind() == reflect.String:
+			field.SetString(value)
+		case field.Kind() == reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("некорректное значение %s=%q: %w", name, value, err)
+			}
+			field.SetInt(int64(n))
+		case field.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("некорректное значение %s=%q: %w", name, value, err)
+			}
+			field.SetBool(b)
+		}
+	}
+	return nil
+}
+
+// Validate проверяет настройки, необходимые для запуска бота
+func (c Config) Validate() error {
+	var problems []string
+
+	if c.Telegram.Token == "" {
+		problems = append(problems, "не задан токен бота (telegram.token или TELEGRAM_BOT_TOKEN)")
+	}
+
+	problems = append(problems, c.validateTenants()...)
+
+	if c.Workers.Count < 1 {
+		problems = append(problems, "workers.count должно быть не меньше 1")
+	}
+	if c.Workers.QueueSize < 0 {
+		problems = append(problems, "workers.queue_size не может быть отрицательным")
+	}
+	if c.Sessions.AttemptTTL < 0 {
+		problems = append(problems, "sessions.attempt_ttl не может быть отрицательным")
+	}
+	if c.LeaderboardRefresh <= 0 {
+		problems = append(problems, "leaderboard_refresh должно быть положительным")
+	}
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, "shutdown_timeout должно быть положительным")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("некорректные настройки:\n- %s", strings.Join(problems, "\n- "))
+	}
+	return nil
+}
+
+// validateTenants проверяет классы: уникальность ID, кодов и чатов, а также хранилище каждого класса
+func (c Config) validateTenants() []string {
+	var problems []string
+
+	ids := make(map[string]bool)
+	codes := make(map[string]string)
+	chats := make(map[int64]string)
+	sqlitePaths := make(map[string]string)
+
+	for i, t := range c.TenantConfigs() {
+		if t.ID == "" {
+			problems = append(problems, fmt.Sprintf("tenants[%d]: не задан id", i))
+			continue
+		}
+		if ids[t.ID] {
+			problems = append(problems, fmt.Sprintf("tenants: id %q повторяется", t.ID))
+		}
+		ids[t.ID] = true
+
+		if t.InviteCode != "" {
+			code := strings.ToLower(t.InviteCode)
+			if other, ok := codes[code]; ok {
+				problems = append(problems, fmt.Sprintf("tenants: код %q задан и у %s, и у %s", t.InviteCode, other, t.ID))
+			}
+			codes[code] = t.ID
+		} else if len(c.Tenants) > 1 && len(t.Chats) == 0 {
+			problems = append(problems, fmt.Sprintf("tenants %s: нужен invite_code или chats, иначе ученики не смогут присоединиться", t.ID))
+		}
+		for _, chatID := range t.Chats {
+			if other, ok := chats[chatID]; ok {
+				problems = append(problems, fmt.Sprintf("tenants: чат %d закреплен и за %s, и за %s", chatID, other, t.ID))
+			}
+			chats[chatID] = t.ID
+		}
+
+		tenantCfg := c.ForTenant(t)
+		switch tenantCfg.Storage.Backend {
+		case "sheets":
+			if err := tenantCfg.Sheets.Validate(); err != nil {
+				problems = append(problems, fmt.Sprintf("класс %s: %v", t.ID, err))
+			}
+		case "sqlite":
+			path := tenantCfg.Storage.SQLitePath
+			// В базе вопросы хранятся строками вкладки, поэтому раскладка колонок нужна и здесь
+			if _, err := tenantCfg.Sheets.questionLayout(); err != nil {
+				problems = append(problems, fmt.Sprintf("класс %s: %v", t.ID, err))
+			}
+			if path == "" {
+				problems = append(problems, fmt.Sprintf("класс %s: не задан путь к базе SQLite (storage.sqlite_path)", t.ID))
+			} else if other, ok := sqlitePaths[path]; ok {
+				problems = append(problems, fmt.Sprintf("классы %s и %s используют одну базу SQLite %s", other, t.ID, path))
+			}
+			sqlitePaths[path] = t.ID
+		default:
+			problems = append(problems, fmt.Sprintf("неизвестное хранилище storage.backend=%q (ожидается sheets или sqlite)", tenantCfg.Storage.Backend))
+			return problems
+		}
+	}
+	return problems
+}
+
+// Validate проверяет настройки таблицы: ID, путь к ключу, названия вкладок и диапазоны
+func (s SheetsConfig) Validate() error {
+	var problems []string
+
+	if s.SpreadsheetID == "" {
+		problems = append(problems, "не задан ID таблицы (sheets.spreadsheet_id или SPREADSHEET_ID)")
+	}
+	if s.CredentialsFile == "" {
+		problems = append(problems, "не задан путь к JSON-ключу (sheets.credentials_file)")
+	}
+	if s.LeaderboardSheet == "" || s.TeacherSheet == "" {
+		problems = append(problems, "не заданы названия вкладок Leaderboard и Teacher")
+	}
+	if s.AttemptsSheet == "" || s.AnswersSheet == "" {
+		problems = append(problems, "не заданы названия вкладок Attempts и Answers")
+	}
+	if s.TestsSheet == "" {
+		problems = append(problems, "не задано название вкладки Tests (sheets.tests_sheet)")
+	}
+
+	ranges := []struct{ name, value string }{
+		{"questions_range", s.QuestionsRange},
+		{"results_range", s.ResultsRange},
+		{"settings_range", s.SettingsRange},
+		{"leaderboard_range", s.LeaderboardRange},
+		{"teacher_info_range", s.TeacherInfoRange},
+		{"teacher_description_range", s.TeacherDescriptionRange},
+	}
+	for _, r := range ranges {
+		if _, err := parseA1Range(r.value); err != nil {
+			problems = append(problems, fmt.Sprintf("sheets.%s: %v", r.name, err))
+		}
+	}
+
+	if _, err := s.questionLayout(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if results, err := parseA1Range(s.ResultsRange); err == nil && results.Width() < len(resultsHeader) {
+		problems = append(problems, fmt.Sprintf("sheets.results_range %s: для результатов нужно %d колонок (схема %d), например H2:Q; "+
+			"результаты старой раскладки переносятся командой migrate-results", s.ResultsRange, len(resultsHeader), resultSchemaVersion))
+	}
+	// Вопросы, результаты и настройки лежат в одной вкладке и не должны пересекаться по колонкам
+	testRanges := ranges[:3]
+	for i := range testRanges {
+		for j := i + 1; j < len(testRanges); j++ {
+			a, aErr := parseA1Range(testRanges[i].value)
+			b, bErr := parseA1Range(testRanges[j].value)
+			if aErr == nil && bErr == nil &&
+				columnNumber(a.StartColumn) <= columnNumber(b.EndColumn) &&
+				columnNumber(b.StartColumn) <= columnNumber(a.EndColumn) {
+				problems = append(problems, fmt.Sprintf("sheets.%s %s и sheets.%s %s пересекаются",
+					testRanges[i].name, testRanges[i].value, testRanges[j].name, testRanges[j].value))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// isServiceSheet сообщает, что вкладка служебная и не содержит теста: Leaderboard, Results, Teacher,
+// Attempts, Answers и Tests
+func (s SheetsConfig) isServiceSheet(title string) bool {
+	titleLower := strings.ToLower(title)
+	return strings.Contains(titleLower, strings.ToLower(s.LeaderboardSheet)) ||
+		strings.Contains(titleLower, "results") ||
+		title == s.TeacherSheet ||
+		title == s.AttemptsSheet ||
+		title == s.AnswersSheet ||
+		title == s.TestsSheet
+}
+
+// a1Range — диапазон вида "H2:K": начальная колонка, первая строка и конечная колонка
+type a1Range struct {
+	StartColumn string
+	StartRow    int
+	EndColumn   string
+}
+
+var a1RangePattern = regexp.MustCompile(`^([A-Z]+)([0-9]+):([A-Z]+)[0-9]*$`)
+
+// parseA1Range разбирает диапазон вида "A2:F" или "A2:A10"
+func parseA1Range(value string) (a1Range, error) {
+	m := a1RangePattern.FindStringSubmatch(value)
+	if m == nil {
+		return a1Range{}, fmt.Errorf("диапазон %q должен иметь вид A2:F", value)
+	}
+	row, _ := strconv.Atoi(m[2])
+	if row < 1 {
+		return a1Range{}, fmt.Errorf("диапазон %q должен начинаться со строки 1 или ниже", value)
+	}
+	return a1Range{StartColumn: m[1], StartRow: row, EndColumn: m[3]}, nil
+}
+
+// String возвращает диапазон в виде "H2:Q"
+func (r a1Range) String() string {
+	return fmt.Sprintf("%s%d:%s", r.StartColumn, r.StartRow, r.EndColumn)
+}
+
+// Columns возвращает диапазон из целых колонок ("H:Q") — для добавления строк в конец
+func (r a1Range) Columns() string {
+	return r.StartColumn + ":" + r.EndColumn
+}
+
+// Width возвращает число колонок диапазона
+func (r a1Range) Width() int {
+	return columnNumber(r.EndColumn) - columnNumber(r.StartColumn) + 1
+}
+
+// Cell возвращает адрес ячейки начальной колонки в строке с индексом i внутри диапазона
+func (r a1Range) Cell(i int) string {
+	return fmt.Sprintf("%s%d", r.StartColumn, r.StartRow+i)
+}
+
+var (
+	columnPattern      = regexp.MustCompile(`^[A-Z]+$`)
+	columnRangePattern = regexp.MustCompile(`^([A-Z]+):([A-Z]+)$`)
+)
+
+// columnNumber переводит букву колонки в ее номер: A — 1, Z — 26, AA — 27
+func columnNumber(column string) int {
+	n := 0
+	for _, ch := range column {
+		n = n*26 + int(ch-'A'+1)
+	}
+	return n
+}
+
+// columnLetter переводит номер колонки в букву: 1 — A, 26 — Z, 27 — AA
+func columnLetter(n int) string {
+	var letters []byte
+	for ; n > 0; n = (n - 1) / 26 {
+		letters = append([]byte{byte('A' + (n-1)%26)}, letters...)
+	}
+	return string(letters)
+}
+
+// questionLayout — положение частей вопроса в строке диапазона вопросов (индексы с нуля)
+type questionLayout struct {
+	// Range — сам диапазон вопросов: по нему ошибки в строках указывают адрес ячейки
+	Range       a1Range
+	ID          int
+	Question    int
+	OptionsFrom int
+	OptionsTo   int
+	Answer      int
+	// Необязательные колонки; -1, если колонка не задана
+	Type        int
+	Explanation int
+	Points      int
+	Photo       int
+	Audio       int
+	Video       int
+}
+
+// MaxOptions возвращает наибольшее число вариантов ответа
+func (l questionLayout) MaxOptions() int {
+	return l.OptionsTo - l.OptionsFrom + 1
+}
+
+// questionLayout переводит буквы колонок из QuestionColumns в индексы внутри questions_range
+func (s SheetsConfig) questionLayout() (questionLayout, error) {
+	questions, err := parseA1Range(s.QuestionsRange)
+	if err != nil {
+		return questionLayout{}, fmt.Errorf("sheets.questions_range: %w", err)
+	}
+	first, last := columnNumber(questions.StartColumn), columnNumber(questions.EndColumn)
+
+	offset := func(name, column string) (int, error) {
+		if !columnPattern.MatchString(column) {
+			return 0, fmt.Errorf("sheets.question_columns.%s: колонка %q должна быть буквой, например F", name, column)
+		}
+		n := columnNumber(column)
+		if n < first || n > last {
+			return 0, fmt.Errorf("sheets.question_columns.%s: колонка %s вне диапазона вопросов %s", name, column, s.QuestionsRange)
+		}
+		return n - first, nil
+	}
+
+	layout := questionLayout{Range: questions}
+	cols := s.QuestionColumns
+	if layout.ID, err = offset("id", cols.ID); err != nil {
+		return questionLayout{}, err
+	}
+	if layout.Question, err = offset("question", cols.Question); err != nil {
+		return questionLayout{}, err
+	}
+	if layout.Answer, err = offset("answer", cols.Answer); err != nil {
+		return questionLayout{}, err
+	}
+	optional := []struct {
+		name   string
+		column string
+		target *int
+	}{
+		{"type", cols.Type, &layout.Type},
+		{"explanation", cols.Explanation, &layout.Explanation},
+		{"points", cols.Points, &layout.Points},
+		{"photo", cols.Photo, &layout.Photo},
+		{"audio", cols.Audio, &layout.Audio},
+		{"video", cols.Video, &layout.Video},
+	}
+	for _, col := range optional {
+		*col.target = -1
+		if col.column == "" {
+			continue
+		}
+		if *col.target, err = offset(col.name, col.column); err != nil {
+			return questionLayout{}, err
+		}
+	}
+
+	m := columnRangePattern.FindStringSubmatch(cols.Options)
+	if m == nil {
+		return questionLayout{}, fmt.Errorf("sheets.question_columns.options: диапазон %q должен иметь вид C:E", cols.Options)
+	}
+	if layout.OptionsFrom, err = offset("options", m[1]); err != nil {
+		return questionLayout{}, err
+	}
+	if layout.OptionsTo, err = offset("options", m[2]); err != nil {
+		return questionLayout{}, err
+	}
+	if layout.MaxOptions() < 2 {
+		return questionLayout{}, fmt.Errorf("sheets.question_columns.options: в диапазоне %s должно быть не меньше двух колонок", cols.Options)
+	}
+	if layout.Answer >= layout.OptionsFrom && layout.Answer <= layout.OptionsTo {
+		return questionLayout{}, fmt.Errorf("sheets.question_columns.answer: колонка %s попадает в диапазон вариантов %s", cols.Answer, cols.Options)
+	}
+	return layout, nil
+}
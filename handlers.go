@@ -0,0 +1,1052 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tg_bot_module/router"
+)
+
+// app связывает обработчики бота с его зависимостями
+type app struct {
+	bot      *tgbotapi.BotAPI
+	tenants  *TenantRegistry
+	sessions SessionStore
+	// attemptTTL — через сколько времени без активности незавершенная попытка удаляется
+	attemptTTL time.Duration
+
+	// dispatcher выполняет отложенные задачи (истечение времени на вопрос) в очереди чата
+	dispatcher *router.Dispatcher
+	timersMu   sync.Mutex
+	timers     map[SessionKey]*time.Timer
+
+	// background отслеживает фоновые записи, которых нужно дождаться при остановке
+	background sync.WaitGroup
+}
+
+// goBackground запускает fn в отдельной горутине и учитывает ее при остановке бота
+func (a *app) goBackground(fn func()) {
+	a.background.Add(1)
+	go func() {
+		defer a.background.Done()
+		fn()
+	}()
+}
+
+// waitBackground ждет завершения всех фоновых задач
+func (a *app) waitBackground() {
+	a.background.Wait()
+}
+
+// newRouter регистрирует все команды и кнопки бота
+func (a *app) newRouter() *router.Router {
+	r := router.New(a.bot)
+	r.Use(router.Logger(), router.AnswerCallback("Запрос обработан!"), router.Recoverer())
+
+	r.Command("start", a.handleStart)
+	r.Command("info", a.handleInfo)
+	r.Command("tests", a.handleTestsCommand)
+	r.Command("join", a.handleJoin)
+	r.Command("history", a.handleHistory, router.Auth(func(user *tgbotapi.User) bool {
+		return a.tenants.IsAdmin(user.ID)
+	}, "Команда доступна только преподавателям."))
+	r.Command("validate", a.handleValidate, router.Auth(func(user *tgbotapi.User) bool {
+		return a.tenants.IsAdmin(user.ID)
+	}, "Команда доступна только преподавателям."))
+	r.UnknownCommand(a.handleUnknownCommand)
+
+	r.CallbackPrefix("answer_", a.handleAnswer)
+	r.CallbackPrefix("toggle_", a.handleToggleOption)
+	r.CallbackPrefix("submit_", a.handleSubmitAnswer)
+	r.Callback("start_tests", a.handleStartTests)
+	r.CallbackPrefix("select_", a.handleSelectTest)
+	r.Callback("show_lk", a.handleShowLK)
+	r.Callback("show_teacher", a.handleShowTeacher)
+	r.Callback("show_start_menu", a.handleStartMenu)
+	r.Callback("resume_test", a.handleResumeTest)
+	r.Callback("abandon_test", a.handleAbandonTest)
+
+	r.Text(a.handleText)
+	return r
+}
+
+// mainMenuKeyboard возвращает inline-клавиатуру главного меню. Если у пользователя есть
+// незавершенный тест (session != nil), добавляются кнопки "Продолжить" и "Прервать".
+func mainMenuKeyboard(session *Session) tgbotapi.InlineKeyboardMarkup {
+	buttonLK := tgbotapi.NewInlineKeyboardButtonData("ЛК", "show_lk")
+	buttonTests := tgbotapi.NewInlineKeyboardButtonData("Тесты", "start_tests")
+	buttonTeacher := tgbotapi.NewInlineKeyboardButtonData("Преподаватель", "show_teacher")
+
+	// Кнопки в два ряда: [Преподаватель, ЛК], [Тесты]
+	keyboardRow1 := tgbotapi.NewInlineKeyboardRow(buttonTeacher, buttonLK)
+	keyboardRow2 := tgbotapi.NewInlineKeyboardRow(buttonTests)
+	if session == nil {
+		return tgbotapi.NewInlineKeyboardMarkup(keyboardRow1, keyboardRow2)
+	}
+
+	buttonResume := tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("▶️ Продолжить тест «%s»", session.TestName), "resume_test")
+	buttonAbandon := tgbotapi.NewInlineKeyboardButtonData("✖️ Прервать тест", "abandon_test")
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(buttonResume),
+		tgbotapi.NewInlineKeyboardRow(buttonAbandon),
+		keyboardRow1, keyboardRow2)
+}
+
+// backKeyboard возвращает клавиатуру с одной кнопкой "Назад" в главное меню
+func backKeyboard() tgbotapi.InlineKeyboardMarkup {
+	backButton := tgbotapi.NewInlineKeyboardButtonData("⏪ Назад", "show_start_menu")
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(backButton))
+}
+
+// tenant определяет класс пользователя. Если класс определить нельзя, пользователю
+// отправляется подсказка про /join и возвращается false.
+func (a *app) tenant(c *router.Context) (*Tenant, bool) {
+	tenant, ok := a.tenants.Resolve(c.ChatID(), c.From().ID)
+	if !ok {
+		a.bot.Send(tgbotapi.NewMessage(c.ChatID(), "Вы пока не присоединились к классу. Отправьте /join <код>, который выдал преподаватель."))
+	}
+	return tenant, ok
+}
+
+// --- КОМАНДЫ ---
+
+func (a *app) handleStart(c *router.Context) error {
+	msg := tgbotapi.NewMessage(c.ChatID(), "Привет! Я бот на GoLang. Выберите действие.")
+	msg.ReplyMarkup = a.mainMenu(c)
+	_, err := a.bot.Send(msg)
+	return err
+}
+
+func (a *app) handleInfo(c *router.Context) error {
+	from := c.Update.Message.From
+	response := fmt.Sprintf(
+		"Ваша информация:\nID: %d\nИмя: %s\nЮзернейм: @%s",
+		from.ID, from.FirstName, from.UserName)
+	_, err := a.bot.Send(tgbotapi.NewMessage(c.ChatID(), response))
+	return err
+}
+
+func (a *app) handleTestsCommand(c *router.Context) error {
+	msg := tgbotapi.NewMessage(c.ChatID(), "Выберите кнопку 'Тесты', чтобы увидеть список доступных викторин.")
+	msg.ReplyMarkup = a.mainMenu(c)
+	_, err := a.bot.Send(msg)
+	return err
+}
+
+// handleJoin привязывает ученика к классу по коду приглашения (/join <код>)
+func (a *app) handleJoin(c *router.Context) error {
+	chatID := c.ChatID()
+	code := strings.TrimSpace(c.Message().CommandArguments())
+	if code == "" {
+		_, err := a.bot.Send(tgbotapi.NewMessage(chatID, "Укажите код класса: /join <код>"))
+		return err
+	}
+
+	tenant, err := a.tenants.Join(c.From().ID, code)
+	if errors.Is(err, ErrUnknownInviteCode) {
+		_, err := a.bot.Send(tgbotapi.NewMessage(chatID, "Класс с таким кодом не найден. Проверьте код у преподавателя."))
+		return err
+	}
+	if err != nil {
+		a.bot.Send(tgbotapi.NewMessage(chatID, "Не удалось присоединиться к классу, попробуйте позже."))
+		return fmt.Errorf("не удалось привязать пользователя [%s] к классу: %w", c.Username(), err)
+	}
+	log.Printf("Пользователь [%s] присоединился к классу %s", c.Username(), tenant.ID)
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Вы присоединились к классу «%s». Выберите действие.", tenant.DisplayName()))
+	msg.ReplyMarkup = a.mainMenu(c)
+	_, err = a.bot.Send(msg)
+	return err
+}
+
+func (a *app) handleUnknownCommand(c *router.Context) error {
+	_, err := a.bot.Send(tgbotapi.NewMessage(c.ChatID(), "Неизвестная команда."))
+	return err
+}
+
+// handleText принимает свободный ответ, если пользователь сейчас отвечает на вопрос
+// со свободным ответом; остальные сообщения обрабатываются как раньше (эхо)
+func (a *app) handleText(c *router.Context) error {
+	message := c.Message()
+	key := SessionKey{ChatID: c.ChatID(), UserID: message.From.ID}
+
+	var qIndex int
+	var credit float64
+	var late lateAnswer
+	session, err := a.updateAttempt(c, key, func(s *Session) error {
+		question, ok := s.CurrentQuestion()
+		if !ok || question.Type != QuestionText {
+			return errNotTextQuestion
+		}
+		qIndex = s.Position
+		if late.check(s, time.Now()) {
+			return nil
+		}
+		// Стикер, фото или команда — не ответ: вопрос остается открытым
+		if strings.TrimSpace(message.Text) == "" || message.IsCommand() {
+			return errNotAnswerText
+		}
+		credit = s.AnswerText(message.Text)
+		return nil
+	})
+	if errors.Is(err, ErrSessionNotFound) || errors.Is(err, errNotTextQuestion) {
+		return a.handleEcho(c)
+	}
+	if errors.Is(err, errNotAnswerText) {
+		reply := tgbotapi.NewMessage(c.ChatID(), fmt.Sprintf("Вопрос %d ждет ответа текстом. Напишите ответ сообщением.", qIndex+1))
+		reply.ReplyToMessageID = message.MessageID
+		_, err := a.bot.Send(reply)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось обновить сессию пользователя [%s]: %w", c.Username(), err)
+	}
+	if late.timedOut {
+		return a.questionTimedOut(c, session, qIndex, late.messageID, late.testTimeUp)
+	}
+
+	if credit > 0 {
+		log.Printf("Пользователь [%s] ответил верно!", c.Username())
+	} else {
+		log.Printf("Пользователь [%s] ответил неверно: %q", c.Username(), message.Text)
+	}
+
+	text := fmt.Sprintf("Ответ на вопрос %d принят. Загружаю следующий...", qIndex+1)
+	if feedback, ok := instantFeedback(session, qIndex); ok {
+		text = feedback
+	}
+	reply := tgbotapi.NewMessage(c.ChatID(), text)
+	reply.ReplyToMessageID = message.MessageID
+	a.bot.Send(reply)
+
+	return a.sendQuestion(c, session)
+}
+
+// handleEcho — ЛОГИКА "ЭХО" для обычных сообщений
+func (a *app) handleEcho(c *router.Context) error {
+	_, err := a.bot.Send(tgbotapi.NewMessage(c.ChatID(), c.Update.Message.Text))
+	return err
+}
+
+// --- КНОПКИ ---
+
+// handleAnswer обрабатывает ответ на вопрос с одним вариантом (answer_<попытка>|<вопрос>|<вариант>)
+func (a *app) handleAnswer(c *router.Context) error {
+	callback := c.Callback()
+	cb, ok := parseQuestionCallback(callback.Data, "answer_")
+	if !ok {
+		c.Alert(staleButtonAlert)
+		return nil
+	}
+	qIndex := cb.Question
+	key := SessionKey{ChatID: c.ChatID(), UserID: callback.From.ID}
+
+	// Проверка ответа и переход к следующему вопросу выполняются атомарно,
+	// поэтому повторное нажатие уже не застанет вопрос текущим
+	var credit float64
+	var late lateAnswer
+	session, err := a.updateAttempt(c, key, func(s *Session) error {
+		if !cb.current(s) {
+			return errStaleQuestion
+		}
+		original, ok := s.OriginalOption(qIndex, cb.Option)
+		if !ok {
+			return errStaleQuestion
+		}
+		if late.check(s, time.Now()) {
+			return nil
+		}
+		credit = s.Answer([]int{original})
+		return nil
+	})
+	if errors.Is(err, ErrSessionNotFound) || errors.Is(err, errStaleQuestion) {
+		c.Alert(staleButtonAlert)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось обновить сессию пользователя [%s]: %w", callback.From.UserName, err)
+	}
+	if late.timedOut {
+		c.Alert("⏰ Время на ответ вышло.")
+		return a.questionTimedOut(c, session, qIndex, late.messageID, late.testTimeUp)
+	}
+
+	return a.questionAnswered(c, session, qIndex, credit)
+}
+
+// handleToggleOption отмечает вариант в вопросе с несколькими ответами (toggle_<попытка>|<вопрос>|<вариант>)
+// и перерисовывает клавиатуру с галочками
+func (a *app) handleToggleOption(c *router.Context) error {
+	callback := c.Callback()
+	cb, ok := parseQuestionCallback(callback.Data, "toggle_")
+	if !ok {
+		c.Alert(staleButtonAlert)
+		return nil
+	}
+	qIndex := cb.Question
+	key := SessionKey{ChatID: c.ChatID(), UserID: callback.From.ID}
+
+	var late lateAnswer
+	session, err := a.updateAttempt(c, key, func(s *Session) error {
+		if !cb.current(s) {
+			return errStaleQuestion
+		}
+		original, ok := s.OriginalOption(qIndex, cb.Option)
+		if !ok {
+			return errStaleQuestion
+		}
+		if late.check(s, time.Now()) {
+			return nil
+		}
+		s.ToggleSelected(original)
+		return nil
+	})
+	if errors.Is(err, ErrSessionNotFound) || errors.Is(err, errStaleQuestion) {
+		c.Alert(staleButtonAlert)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось обновить сессию пользователя [%s]: %w", callback.From.UserName, err)
+	}
+	if late.timedOut {
+		c.Alert("⏰ Время на ответ вышло.")
+		return a.questionTimedOut(c, session, qIndex, late.messageID, late.testTimeUp)
+	}
+
+	c.Answer("")
+	editMarkup := tgbotapi.NewEditMessageReplyMarkup(c.ChatID(), callback.Message.MessageID, questionKeyboard(session))
+	_, err = a.bot.Send(editMarkup)
+	return err
+}
+
+// handleSubmitAnswer засчитывает отмеченные варианты вопроса с несколькими ответами (submit_<попытка>|<вопрос>)
+func (a *app) handleSubmitAnswer(c *router.Context) error {
+	callback := c.Callback()
+	cb, ok := parseQuestionCallback(callback.Data, "submit_")
+	if !ok {
+		c.Alert(staleButtonAlert)
+		return nil
+	}
+	qIndex := cb.Question
+	key := SessionKey{ChatID: c.ChatID(), UserID: callback.From.ID}
+
+	var credit float64
+	var late lateAnswer
+	session, err := a.updateAttempt(c, key, func(s *Session) error {
+		if !cb.current(s) {
+			return errStaleQuestion
+		}
+		if late.check(s, time.Now()) {
+			return nil
+		}
+		if len(s.Selected) == 0 {
+			return errNothingSelected
+		}
+		credit = s.Answer(s.Selected)
+		return nil
+	})
+	if errors.Is(err, errNothingSelected) {
+		c.Alert("Отметьте хотя бы один вариант.")
+		return nil
+	}
+	if errors.Is(err, ErrSessionNotFound) || errors.Is(err, errStaleQuestion) {
+		c.Alert(staleButtonAlert)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось обновить сессию пользователя [%s]: %w", callback.From.UserName, err)
+	}
+	if late.timedOut {
+		c.Alert("⏰ Время на ответ вышло.")
+		return a.questionTimedOut(c, session, qIndex, late.messageID, late.testTimeUp)
+	}
+
+	return a.questionAnswered(c, session, qIndex, credit)
+}
+
+var (
+	// errStaleQuestion — нажата кнопка вопроса, на который уже ответили
+	errStaleQuestion = errors.New("ответ на устаревший вопрос")
+	// errNothingSelected — нажата кнопка "Готово", но ни один вариант не отмечен
+	errNothingSelected = errors.New("не выбран ни один вариант")
+	// errNotTextQuestion — сообщение пришло, когда пользователь не отвечает на вопрос со свободным ответом
+	errNotTextQuestion = errors.New("текущий вопрос не требует свободного ответа")
+	// errNotAnswerText — на вопрос со свободным ответом пришло сообщение без текста или команда
+	errNotAnswerText = errors.New("сообщение не содержит ответа")
+)
+
+// staleButtonAlert показывается при нажатии кнопки вопроса, который уже не актуален
+const staleButtonAlert = "⚠️ Эта кнопка устарела: на вопрос уже ответили или попытка завершена."
+
+// questionCallback — данные кнопки вопроса: попытка, номер вопроса (с нуля) и номер варианта
+// на экране (с единицы; у кнопки "Готово" варианта нет)
+type questionCallback struct {
+	Attempt  string
+	Question int
+	Option   int
+}
+
+// questionCallbackData собирает данные кнопки вида <prefix><попытка>|<вопрос>[|<вариант>]
+func questionCallbackData(prefix string, session *Session, qIndex, option int) string {
+	data := fmt.Sprintf("%s%s|%d", prefix, session.AttemptID, qIndex)
+	if option > 0 {
+		data += fmt.Sprintf("|%d", option)
+	}
+	return data
+}
+
+// parseQuestionCallback разбирает данные кнопки, собранные questionCallbackData
+func parseQuestionCallback(data, prefix string) (questionCallback, bool) {
+	parts := strings.Split(strings.TrimPrefix(data, prefix), "|")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return questionCallback{}, false
+	}
+	cb := questionCallback{Attempt: parts[0]}
+	var err error
+	if cb.Question, err = strconv.Atoi(parts[1]); err != nil {
+		return questionCallback{}, false
+	}
+	if len(parts) == 3 {
+		if cb.Option, err = strconv.Atoi(parts[2]); err != nil {
+			return questionCallback{}, false
+		}
+	}
+	return cb, true
+}
+
+// current сообщает, что кнопка относится к текущему вопросу текущей попытки сессии
+func (cb questionCallback) current(s *Session) bool {
+	return cb.Attempt == s.AttemptID && cb.Question == s.Position && !s.Finished()
+}
+
+// questionAnswered убирает кнопки у отвеченного вопроса и отправляет следующий
+func (a *app) questionAnswered(c *router.Context, session *Session, qIndex int, credit float64) error {
+	callback := c.Callback()
+	switch {
+	case credit >= 1:
+		log.Printf("Пользователь [%s] ответил верно!", callback.From.UserName)
+	case credit > 0:
+		log.Printf("Пользователь [%s] ответил частично верно (%s).", callback.From.UserName, formatPoints(credit))
+	default:
+		log.Printf("Пользователь [%s] ответил неверно.", callback.From.UserName)
+	}
+
+	text := fmt.Sprintf("Вы ответили на вопрос %d. Загружаю следующий...", qIndex+1)
+	if feedback, ok := instantFeedback(session, qIndex); ok {
+		text = feedback
+	}
+	if err := a.editQuestionMessage(c.ChatID(), callback.Message.MessageID, text); err != nil {
+		log.Printf("Не удалось изменить сообщение с вопросом: %v", err)
+	}
+
+	return a.sendQuestion(c, session)
+}
+
+// instantFeedback возвращает текст с оценкой ответа на вопрос qIndex и пояснением,
+// если в настройках теста включена обратная связь после каждого ответа
+func instantFeedback(session *Session, qIndex int) (string, bool) {
+	if session.Settings.Feedback != FeedbackInstant || qIndex >= len(session.Answers) {
+		return "", false
+	}
+	question := session.Questions[qIndex]
+	text := fmt.Sprintf("Вопрос %d: %s\n\n%s", qIndex+1, question.Question, answerFeedback(question, session.Answers[qIndex]))
+	return text, true
+}
+
+// handleStartTests показывает список доступных тестов (нажатие кнопки "Тесты")
+func (a *app) handleStartTests(c *router.Context) error {
+	chatID := c.ChatID()
+	tenant, ok := a.tenant(c)
+	if !ok {
+		return nil
+	}
+
+	catalog, err := tenant.Repo.TestCatalog(c)
+	if err != nil {
+		a.bot.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить список тестов. Проверьте настройки таблицы."))
+		return fmt.Errorf("ошибка при получении списка тестов: %w", err)
+	}
+	now := time.Now()
+	tests := catalog.Listed(now)
+	if len(tests) == 0 {
+		_, err := a.bot.Send(tgbotapi.NewMessage(chatID, "Сейчас нет доступных тестов."))
+		return err
+	}
+
+	// Кнопки идут в том же порядке, что и тесты в тексте; у неоткрытых тестов кнопки нет
+	var testButtons [][]tgbotapi.InlineKeyboardButton
+	for _, category := range groupByCategory(tests) {
+		for _, info := range category.Tests {
+			if !info.Open(now) {
+				continue
+			}
+			btn := tgbotapi.NewInlineKeyboardButtonData(info.DisplayName(), "select_"+info.Name)
+			testButtons = append(testButtons, tgbotapi.NewInlineKeyboardRow(btn))
+		}
+	}
+
+	backButton := tgbotapi.NewInlineKeyboardButtonData("⏪ Назад", "show_start_menu")
+	testButtons = append(testButtons, tgbotapi.NewInlineKeyboardRow(backButton))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(testButtons...)
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, c.Callback().Message.MessageID, testListText(tests, now))
+	editMsg.ReplyMarkup = &keyboard
+	_, err = a.bot.Send(editMsg)
+	return err
+}
+
+// handleSelectTest загружает выбранный тест (select_ИмяВкладки) и задает первый вопрос
+func (a *app) handleSelectTest(c *router.Context) error {
+	callback := c.Callback()
+	chatID := c.ChatID()
+	testName := strings.TrimPrefix(callback.Data, "select_")
+	log.Printf("Пользователь [%s] выбрал тест: %s", callback.From.UserName, testName)
+	tenant, ok := a.tenant(c)
+	if !ok {
+		return nil
+	}
+
+	// 1. Тест должен быть виден ученикам и открыт: кнопка могла остаться в старом сообщении
+	catalog, err := tenant.Repo.TestCatalog(c)
+	if err != nil {
+		a.bot.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить список тестов, попробуйте позже."))
+		return fmt.Errorf("ошибка при получении списка тестов: %w", err)
+	}
+	if info, ok := catalog.Find(testName); !ok || !info.Open(time.Now()) {
+		log.Printf("Пользователю [%s] отказано в тесте %s: тест скрыт или закрыт", callback.From.UserName, testName)
+		msg := tgbotapi.NewMessage(chatID, "Этот тест сейчас недоступен.")
+		msg.ReplyMarkup = backKeyboard()
+		_, err := a.bot.Send(msg)
+		return err
+	}
+
+	// Загрузка выбранного теста
+	test, err := tenant.Repo.LoadTest(c, testName)
+	if err != nil {
+		text := fmt.Sprintf("Ошибка загрузки вопросов из вкладки %s. Убедитесь, что данные начинаются с A2.", testName)
+		a.bot.Send(tgbotapi.NewMessage(chatID, text))
+		return fmt.Errorf("ошибка при загрузке теста %s: %w", testName, err)
+	}
+
+	// 2. Проверка правил пересдачи: лимит попыток и пауза между ними
+	if test.Settings.MaxAttempts > 0 || test.Settings.RetakeCooldown > 0 {
+		attempts, err := tenant.Repo.Attempts(c, AttemptQuery{UserID: callback.From.ID, TestName: testName})
+		if err != nil {
+			a.bot.Send(tgbotapi.NewMessage(chatID, "Не удалось проверить предыдущие попытки, попробуйте позже."))
+			return fmt.Errorf("ошибка при загрузке истории попыток теста %s: %w", testName, err)
+		}
+		// Незавершенная попытка этого же теста будет прервана новой и тоже расходует попытку
+		key := SessionKey{ChatID: chatID, UserID: callback.From.ID}
+		if previous, ok := a.activeSession(c, key); ok && previous.TestName == testName {
+			attempts = withAttempt(attempts, interruptedAttempt(previous, time.Now()))
+		}
+		if refusal := retakeRefusal(test.Settings, attempts, time.Now()); refusal != "" {
+			log.Printf("Пользователю [%s] отказано в новой попытке теста %s: %s", callback.From.UserName, testName, refusal)
+			msg := tgbotapi.NewMessage(chatID, refusal)
+			msg.ReplyMarkup = backKeyboard()
+			_, err := a.bot.Send(msg)
+			return err
+		}
+	}
+
+	// 3. Инициализация и старт теста в собственной сессии пользователя
+	// Вопросы и варианты перемешиваются для каждой попытки заново (если это включено в настройках теста)
+	questions, optionOrder := prepareAttempt(test)
+	session := &Session{
+		ChatID:      chatID,
+		UserID:      callback.From.ID,
+		Username:    c.Username(),
+		TenantID:    tenant.ID,
+		AttemptID:   newAttemptID(),
+		TestName:    testName,
+		Questions:   questions,
+		OptionOrder: optionOrder,
+		Settings:    test.Settings,
+		StartedAt:   time.Now(),
+	}
+	if limit := test.Settings.TestTimeLimit; limit > 0 {
+		session.Deadline = session.StartedAt.Add(limit)
+	}
+	// Новый тест заменяет незавершенный: тот записывается в историю как прерванный,
+	// а его кнопки и таймер больше не действуют
+	if previous, ok := a.sessions.Get(session.Key()); ok {
+		interruptedAt := session.StartedAt
+		if previous.Expired(interruptedAt, a.attemptTTL) {
+			interruptedAt = previous.lastActive()
+		}
+		a.interruptAttempt(c, previous, interruptedAt)
+		a.removeQuestionKeyboard(chatID, previous.QuestionMessageID)
+	}
+	if err := a.sessions.Put(session); err != nil {
+		return fmt.Errorf("не удалось сохранить сессию пользователя [%s]: %w", callback.From.UserName, err)
+	}
+
+	deleteMsg := tgbotapi.NewDeleteMessage(chatID, callback.Message.MessageID)
+	a.bot.Send(deleteMsg)
+
+	return a.sendQuestion(c, session)
+}
+
+// handleShowLK показывает личный кабинет (чтение из Leaderboard)
+func (a *app) handleShowLK(c *router.Context) error {
+	callback := c.Callback()
+	chatID := c.ChatID()
+	userID := callback.From.ID
+	tenant, ok := a.tenant(c)
+	if !ok {
+		return nil
+	}
+
+	stats, err := tenant.Repo.UserStats(c, userID)
+	if err != nil {
+		a.bot.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить вашу статистику."))
+		return fmt.Errorf("ошибка получения статистики из Leaderboard: %w", err)
+	}
+
+	fullName := callback.From.FirstName
+	if callback.From.LastName != "" {
+		fullName += " " + callback.From.LastName
+	} else if fullName == "" {
+		fullName = fmt.Sprintf("ID: %d", userID)
+	}
+
+	scoreText := formatPoints(stats.TotalScore)
+	if stats.TotalScore == 0 && stats.TotalPassed == 0 {
+		scoreText = "Нет пройденных тестов"
+	}
+
+	response := fmt.Sprintf(
+		"📊 *Личный Кабинет*\n"+
+			"Имя/Фамилия: %s\n"+
+			"Общий балл: %s\n"+
+			"Сдано на зачет тестов: %d",
+		fullName,
+		scoreText,
+		stats.TotalPassed,
+	)
+
+	attempts, err := tenant.Repo.Attempts(c, AttemptQuery{UserID: userID, Limit: lkHistoryLimit})
+	if err != nil {
+		log.Printf("Не удалось загрузить историю попыток пользователя [%s]: %v", callback.From.UserName, err)
+	}
+	if len(attempts) > 0 {
+		response += "\n\n🕘 *Последние попытки:*"
+		for _, attempt := range attempts {
+			response += "\n• " + tgbotapi.EscapeText(tgbotapi.ModeMarkdown, attemptSummary(attempt))
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, response)
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	msg.ReplyMarkup = backKeyboard()
+
+	_, err = a.bot.Send(msg)
+	return err
+}
+
+// lkHistoryLimit — сколько последних попыток показывать в личном кабинете
+const lkHistoryLimit = 5
+
+// teacherHistoryLimit — сколько последних попыток ученика показывать преподавателю
+const teacherHistoryLimit = 10
+
+// handleHistory показывает преподавателю ошибки ученика в последних попытках (/history <ID или @username>)
+func (a *app) handleHistory(c *router.Context) error {
+	chatID := c.ChatID()
+	tenant, ok := a.tenants.AdminTenant(chatID, c.From().ID)
+	if !ok {
+		return nil
+	}
+
+	student := strings.TrimSpace(c.Message().CommandArguments())
+	if student == "" {
+		_, err := a.bot.Send(tgbotapi.NewMessage(chatID, "Укажите ученика: /history <ID> или /history @username"))
+		return err
+	}
+	query := AttemptQuery{Username: student, Limit: teacherHistoryLimit}
+	if userID, err := strconv.ParseInt(student, 10, 64); err == nil {
+		query = AttemptQuery{UserID: userID, Limit: teacherHistoryLimit}
+	}
+
+	attempts, err := tenant.Repo.Attempts(c, query)
+	if err != nil {
+		a.bot.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить историю попыток."))
+		return fmt.Errorf("ошибка получения истории попыток ученика %s: %w", student, err)
+	}
+	if len(attempts) == 0 {
+		_, err := a.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("У ученика %s нет сохраненных попыток в классе «%s».", student, tenant.DisplayName())))
+		return err
+	}
+
+	for _, text := range wrongAnswersMessages(student, attempts) {
+		if _, err := a.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleValidate проверяет тесты класса (/validate или /validate <тест>) и присылает преподавателю отчет.
+// Длинный отчет отправляется файлом.
+func (a *app) handleValidate(c *router.Context) error {
+	chatID := c.ChatID()
+	tenant, ok := a.tenants.AdminTenant(chatID, c.From().ID)
+	if !ok {
+		return nil
+	}
+
+	var names []string
+	if testName := strings.TrimSpace(c.Message().CommandArguments()); testName != "" {
+		names = append(names, testName)
+	}
+	reports, err := validateTests(c, tenant.Repo, names...)
+	if err != nil {
+		a.bot.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить список тестов."))
+		return fmt.Errorf("ошибка проверки тестов класса %s: %w", tenant.ID, err)
+	}
+
+	report := validationReportText(tenant.DisplayName(), reports)
+	if len(report) <= maxMessageLength {
+		_, err = a.bot.Send(tgbotapi.NewMessage(chatID, report))
+		return err
+	}
+	document := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: "validate.txt", Bytes: []byte(report)})
+	document.Caption = fmt.Sprintf("Найдено ошибок: %d. Отчет — в файле.", countProblems(reports))
+	_, err = a.bot.Send(document)
+	return err
+}
+
+// handleShowTeacher показывает информацию о преподавателе
+func (a *app) handleShowTeacher(c *router.Context) error {
+	chatID := c.ChatID()
+	messageID := c.Callback().Message.MessageID
+	keyboard := backKeyboard()
+	tenant, ok := a.tenant(c)
+	if !ok {
+		return nil
+	}
+
+	teacherInfo, err := tenant.Repo.TeacherProfile(c)
+	if err != nil {
+		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, "⚠️ Не удалось загрузить информацию о преподавателе. Проверьте вкладку 'Teacher' и новый диапазон ячеек.")
+		editMsg.ReplyMarkup = &keyboard
+		a.bot.Send(editMsg)
+		return fmt.Errorf("ошибка загрузки данных преподавателя: %w", err)
+	}
+
+	// 1. Формируем ТЕКСТ (Имя + Описание + Контакты)
+	response := fmt.Sprintf(
+		"🧑‍🏫 *%s*\n\n"+
+			"%s\n\n"+
+			"✉️ Контакты: %s",
+		teacherInfo.Name,
+		teacherInfo.Description,
+		teacherInfo.Contacts,
+	)
+
+	lastMsgID := messageID
+
+	// Удаляем исходное сообщение-кнопку
+	a.bot.Send(tgbotapi.NewDeleteMessage(chatID, messageID))
+
+	// --- 2. Отправка Фото + Текст (в подписи) ---
+	photoSent := false
+	if photoURL := teacherInfo.PhotoURL; photoURL != "" {
+		photoMsg := tgbotapi.NewPhoto(chatID, tgbotapi.FileURL(photoURL))
+		photoMsg.Caption = response
+		photoMsg.ParseMode = tgbotapi.ModeMarkdown
+
+		if sentMsg, err := a.bot.Send(photoMsg); err == nil {
+			photoSent = true
+			lastMsgID = sentMsg.MessageID
+		} else {
+			log.Printf("Не удалось отправить фото преподавателя (URL: %s): %v. Отправка только текста.", photoURL, err)
+		}
+	}
+
+	// Если фото не было отправлено, отправляем только текст (новое сообщение)
+	if !photoSent {
+		newMsg := tgbotapi.NewMessage(chatID, response)
+		newMsg.ParseMode = tgbotapi.ModeMarkdown
+
+		if sentMsg, err := a.bot.Send(newMsg); err == nil {
+			lastMsgID = sentMsg.MessageID
+		}
+	}
+
+	// --- 3. Отправка Видео ---
+	if videoURL := teacherInfo.VideoURL; videoURL != "" {
+		videoMsg := tgbotapi.NewVideo(chatID, tgbotapi.FileURL(videoURL))
+
+		if sentMsg, err := a.bot.Send(videoMsg); err == nil {
+			lastMsgID = sentMsg.MessageID
+		} else {
+			log.Printf("Не удалось отправить видео (URL: %s): %v.", videoURL, err)
+		}
+	}
+
+	// --- 4. Отправка Аудио ---
+	if audioURL := teacherInfo.AudioURL; audioURL != "" {
+		audioMsg := tgbotapi.NewAudio(chatID, tgbotapi.FileURL(audioURL))
+
+		if sentMsg, err := a.bot.Send(audioMsg); err == nil {
+			lastMsgID = sentMsg.MessageID
+		} else {
+			log.Printf("Не удалось отправить аудио (URL: %s): %v.", audioURL, err)
+		}
+	}
+
+	// --- 5. Прикрепляем кнопку "Назад" к последнему отправленному сообщению ---
+	if lastMsgID != 0 {
+		editMarkup := tgbotapi.NewEditMessageReplyMarkup(chatID, lastMsgID, keyboard)
+		a.bot.Send(editMarkup)
+	}
+	return nil
+}
+
+// handleStartMenu возвращает пользователя в главное меню (кнопка "Назад")
+func (a *app) handleStartMenu(c *router.Context) error {
+	chatID := c.ChatID()
+	msgText := "Привет! Выберите действие:"
+	keyboard := a.mainMenu(c)
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, c.Callback().Message.MessageID, msgText)
+	editMsg.ReplyMarkup = &keyboard
+
+	if _, err := a.bot.Send(editMsg); err != nil {
+		newMsg := tgbotapi.NewMessage(chatID, msgText)
+		newMsg.ReplyMarkup = keyboard
+		_, err = a.bot.Send(newMsg)
+		return err
+	}
+	return nil
+}
+
+// --- ПРОХОЖДЕНИЕ ТЕСТА ---
+
+// sendQuestion отправляет текущий вопрос сессии пользователю, а после последнего вопроса
+// сохраняет результат и завершает сессию
+func (a *app) sendQuestion(ctx context.Context, session *Session) error {
+	chatID := session.ChatID
+	qIndex := session.Position
+
+	now := time.Now()
+	if session.Finished() || session.TestTimeUp(now) {
+		return a.finishTest(ctx, session)
+	}
+
+	question := session.Questions[qIndex]
+	deadline := session.NextQuestionDeadline(now)
+	if !session.QuestionDeadline.IsZero() {
+		// Вопрос задается повторно (пользователь продолжил тест): срок ответа не продлевается
+		deadline = session.QuestionDeadline
+	}
+
+	text := fmt.Sprintf("Вопрос %d/%d: %s", qIndex+1, len(session.Questions), question.Question)
+	if !deadline.IsZero() {
+		text += fmt.Sprintf("\n⏱ Время на ответ: %s", formatDuration(deadline.Sub(now)))
+	}
+	var markup interface{}
+	switch question.Type {
+	case QuestionMulti:
+		text += "\n\nОтметьте все верные варианты и нажмите «Готово»."
+		markup = questionKeyboard(session)
+	case QuestionText:
+		text += "\n\nНапишите ответ сообщением."
+	default:
+		markup = questionKeyboard(session)
+	}
+
+	sent, err := a.sendQuestionMessage(chatID, question, text, markup)
+	if err != nil {
+		return fmt.Errorf("ошибка отправки вопроса: %w", err)
+	}
+
+	// Запоминаем сообщение с вопросом и срок ответа; время отсчитывается с момента отправки
+	_, err = a.updateAttempt(ctx, session.Key(), func(s *Session) error {
+		if s.Position != qIndex {
+			return errStaleQuestion
+		}
+		s.QuestionMessageID = sent.MessageID
+		s.QuestionDeadline = deadline
+		return nil
+	})
+	if errors.Is(err, ErrSessionNotFound) || errors.Is(err, errStaleQuestion) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось обновить сессию пользователя [%s]: %w", session.Username, err)
+	}
+
+	if !deadline.IsZero() {
+		a.scheduleTimeout(session.Key(), qIndex, deadline)
+	}
+	return nil
+}
+
+// questionKeyboard строит клавиатуру текущего вопроса сессии. У вопроса с несколькими ответами
+// кнопки отмечают варианты галочкой, а ответ отправляется кнопкой "Готово".
+func questionKeyboard(session *Session) tgbotapi.InlineKeyboardMarkup {
+	qIndex := session.Position
+	question := session.Questions[qIndex]
+
+	// Кнопки нумеруются по позиции на экране; в исходный номер варианта их переводит сессия
+	var buttons []tgbotapi.InlineKeyboardButton
+	var labels []string
+	for i, original := range session.OptionOrderFor(qIndex) {
+		label := question.Options[original-1]
+		callbackData := questionCallbackData("answer_", session, qIndex, i+1)
+		if question.Type == QuestionMulti {
+			callbackData = questionCallbackData("toggle_", session, qIndex, i+1)
+			if session.IsSelected(original) {
+				label = "✅ " + label
+			}
+		}
+		labels = append(labels, label)
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(label, callbackData))
+	}
+
+	rows := optionRows(buttons, labels)
+	if question.Type == QuestionMulti {
+		submit := tgbotapi.NewInlineKeyboardButtonData("Готово ➡️", questionCallbackData("submit_", session, qIndex, 0))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(submit))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// shortOptionLength — варианты не длиннее этого числа символов помещаются по два в ряд
+const shortOptionLength = 16
+
+// optionRows раскладывает кнопки вариантов по рядам: короткие варианты (например, "Да"/"Нет")
+// по два в ряд, длинные — по одному, чтобы текст не обрезался
+func optionRows(buttons []tgbotapi.InlineKeyboardButton, options []string) [][]tgbotapi.InlineKeyboardButton {
+	perRow := 2
+	for _, option := range options {
+		if utf8.RuneCountInString(option) > shortOptionLength {
+			perRow = 1
+			break
+		}
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for len(buttons) > 0 {
+		n := min(perRow, len(buttons))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(buttons[:n]...))
+		buttons = buttons[n:]
+	}
+	return rows
+}
+
+// finishTest записывает результат, обновляет Leaderboard и удаляет сессию
+func (a *app) finishTest(ctx context.Context, session *Session) error {
+	now := time.Now()
+	a.cancelTimeout(session.Key())
+
+	tenant, ok := a.tenants.Get(session.TenantID)
+	if !ok {
+		return fmt.Errorf("класс %q из сессии пользователя [%s] не найден", session.TenantID, session.Username)
+	}
+
+	// Каждая попытка с ответами попадает в историю, а в результатах теста остается
+	// засчитываемая по правилу пересдачи (по умолчанию лучшая)
+	attempt := newAttempt(session, now)
+	if err := tenant.Repo.SaveAttempt(ctx, attempt); err != nil {
+		log.Println("Ошибка записи попытки в историю:", err)
+	}
+
+	// История нужна для номера попытки, среднего балла и числа оставшихся попыток
+	settings := session.Settings
+	result := attempt.Result()
+	var history []Attempt
+	historyLoaded := false
+	loaded, err := tenant.Repo.Attempts(ctx, AttemptQuery{UserID: session.UserID, TestName: session.TestName})
+	if err != nil {
+		log.Printf("Не удалось загрузить историю попыток пользователя [%s]: %v", session.Username, err)
+	} else {
+		history, historyLoaded = withAttempt(loaded, attempt), true
+	}
+	// В среднем учитываются только завершенные попытки, а в номере и лимите — и прерванные
+	averaged := 0
+	if settings.CountedAttempt == CountAverage && historyLoaded {
+		results := attemptResults(history)
+		averaged = len(results)
+		result = countedResult(CountAverage, results)
+		result.Passed = settings.Passed(result.Score, result.MaxScore)
+	}
+	if historyLoaded {
+		result.Attempt = len(history)
+	}
+
+	err = tenant.Repo.SaveResult(ctx, result, settings.CountedAttempt)
+	if err != nil {
+		log.Println("Ошибка записи результата:", err)
+	}
+
+	finalText := fmt.Sprintf("Тест завершен!\nВаш результат: %s из %s (%s%%).",
+		formatPoints(attempt.Score), formatPoints(attempt.MaxScore), formatPoints(attempt.Result().Percent()))
+	if settings.PassPercent > 0 {
+		if attempt.Passed {
+			finalText += "\n✅ Зачет"
+		} else {
+			finalText += fmt.Sprintf("\n❌ Незачет: нужно набрать %s%%", formatPoints(settings.PassPercent))
+		}
+	}
+	if elapsed := session.Elapsed(now); elapsed > 0 {
+		finalText += fmt.Sprintf("\nВремя прохождения: %s.", formatDuration(elapsed))
+	}
+
+	if averaged > 1 {
+		finalText += fmt.Sprintf("\nСредний балл за %d попыток: %s.", averaged, formatPoints(result.Score))
+	}
+	if settings.MaxAttempts > 0 && historyLoaded {
+		finalText += fmt.Sprintf("\nОсталось попыток: %d из %d.", max(0, settings.MaxAttempts-len(history)), settings.MaxAttempts)
+	}
+
+	if err == nil {
+		finalText += "\nРезультат сохранен и обновлен."
+	}
+
+	// Запускаем асинхронное обновление Leaderboard; при остановке бот дождется его завершения
+	a.goBackground(func() {
+		if err := tenant.Repo.UpdateLeaderboard(ctx); err != nil {
+			log.Printf("Ошибка при обновлении Leaderboard класса %s после теста: %v", tenant.ID, err)
+		}
+	})
+
+	// --- КЛАВИАТУРА ПОСЛЕ ТЕСТА ---
+	buttonLK := tgbotapi.NewInlineKeyboardButtonData("ЛК", "show_lk")
+	buttonTests := tgbotapi.NewInlineKeyboardButtonData("Тесты", "start_tests")
+
+	// Кнопка "Назад" ведет в главное меню (show_start_menu)
+	backToMain := tgbotapi.NewInlineKeyboardButtonData("⏪ Назад", "show_start_menu")
+
+	keyboardRow1 := tgbotapi.NewInlineKeyboardRow(buttonTests, buttonLK)
+	keyboardRow2 := tgbotapi.NewInlineKeyboardRow(backToMain)
+	postTestKeyboard := tgbotapi.NewInlineKeyboardMarkup(keyboardRow1, keyboardRow2)
+	// ------------------------------------
+
+	if session.Settings.Feedback == FeedbackReview {
+		for _, text := range reviewMessages(session) {
+			a.bot.Send(tgbotapi.NewMessage(session.ChatID, text))
+		}
+	}
+
+	finalMsg := tgbotapi.NewMessage(session.ChatID, finalText)
+	finalMsg.ReplyMarkup = postTestKeyboard
+	a.bot.Send(finalMsg)
+
+	if err := a.sessions.Delete(session.Key()); err != nil {
+		return fmt.Errorf("ошибка удаления сессии: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tg_bot_module/router"
+)
+
+const (
+	testChatID = int64(100)
+	testUserID = int64(200)
+)
+
+// fakeTelegram — сервер Bot API для тестов: на любой метод отвечает отправленным сообщением
+// и запоминает тексты сообщений бота
+type fakeTelegram struct {
+	mu     sync.Mutex
+	nextID int
+	texts  []string
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if strings.HasSuffix(r.URL.Path, "/getMe") {
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Bot","username":"test_bot"}}`)
+		return
+	}
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		r.ParseForm()
+	}
+
+	f.mu.Lock()
+	f.nextID++
+	id := f.nextID
+	if text := r.FormValue("text"); text != "" {
+		f.texts = append(f.texts, text)
+	}
+	f.mu.Unlock()
+	fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"date":0,"chat":{"id":%d}}}`, id, testChatID)
+}
+
+// Texts возвращает тексты всех сообщений, отправленных ботом
+func (f *fakeTelegram) Texts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.texts...)
+}
+
+// sentText сообщает, что бот отправил сообщение, содержащее substr
+func (f *fakeTelegram) sentText(substr string) bool {
+	for _, text := range f.Texts() {
+		if strings.Contains(text, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// newTestApp создает бота с одним классом на repo и сервером Bot API в памяти
+func newTestApp(t *testing.T, repo Repository) (*app, *router.Router, *fakeTelegram) {
+	t.Helper()
+	telegram := &fakeTelegram{}
+	server := httptest.NewServer(telegram)
+	t.Cleanup(server.Close)
+
+	botAPI, err := tgbotapi.NewBotAPIWithClient("token", server.URL+"/bot%s/%s", server.Client())
+	if err != nil {
+		t.Fatalf("NewBotAPIWithClient: %v", err)
+	}
+
+	tenant := &Tenant{ID: "class", Repo: repo, Admins: map[int64]bool{}}
+	tenants := &TenantRegistry{
+		list:     []*Tenant{tenant},
+		byID:     map[string]*Tenant{tenant.ID: tenant},
+		byChat:   map[int64]*Tenant{},
+		byCode:   map[string]*Tenant{},
+		bindings: map[int64]string{},
+	}
+	a := &app{bot: botAPI, tenants: tenants, sessions: NewMemorySessionStore()}
+	return a, a.newRouter(), telegram
+}
+
+// press нажимает кнопку с данными data от имени ученика
+func press(t *testing.T, r *router.Router, data string) {
+	t.Helper()
+	update := tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "1",
+		From:    &tgbotapi.User{ID: testUserID, UserName: "student"},
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: testChatID}},
+		Data:    data,
+	}}
+	if err := r.Handle(context.Background(), update); err != nil {
+		t.Fatalf("кнопка %s: %v", data, err)
+	}
+}
+
+// answer выбирает вариант option (с единицы) текущего вопроса попытки ученика
+func answer(t *testing.T, a *app, r *router.Router, option int) {
+	t.Helper()
+	session, ok := a.sessions.Get(SessionKey{ChatID: testChatID, UserID: testUserID})
+	if !ok {
+		t.Fatal("нет попытки ученика")
+	}
+	press(t, r, questionCallbackData("answer_", session, session.Position, option))
+}
+
+func newQuizRepository() *MemoryRepository {
+	repo := NewMemoryRepository()
+	repo.AddTest("Тест", [][]interface{}{
+		{"1", "2+2?", "3", "4", "5", "2"},
+		{"2", "Столица Франции?", "Лондон", "Париж", "Берлин", "2"},
+	})
+	return repo
+}
+
+func TestQuizFlowSavesResult(t *testing.T) {
+	repo := newQuizRepository()
+	a, r, telegram := newTestApp(t, repo)
+
+	press(t, r, "select_Тест")
+	answer(t, a, r, 2) // верно
+	answer(t, a, r, 1) // неверно
+	a.waitBackground()
+
+	if _, ok := a.sessions.Get(SessionKey{ChatID: testChatID, UserID: testUserID}); ok {
+		t.Error("попытка не удалена после завершения теста")
+	}
+	if !telegram.sentText("Ваш результат: 1 из 2 (50%).") {
+		t.Errorf("нет сообщения с результатом, отправлено: %q", telegram.Texts())
+	}
+
+	results := repo.Results("Тест")
+	if len(results) != 1 {
+		t.Fatalf("результатов: %d, ожидался 1", len(results))
+	}
+	if got := results[0]; got.UserID != testUserID || got.Score != 1 || got.MaxScore != 2 || got.Attempt != 1 {
+		t.Errorf("результат = %+v", got)
+	}
+
+	attempts, err := repo.Attempts(context.Background(), AttemptQuery{UserID: testUserID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 1 || len(attempts[0].Answers) != 2 || attempts[0].Interrupted {
+		t.Fatalf("история попыток = %+v", attempts)
+	}
+	if wrong := attempts[0].WrongAnswers(); len(wrong) != 1 || wrong[0].QuestionID != "2" {
+		t.Errorf("ошибки попытки = %+v", wrong)
+	}
+
+	// Leaderboard обновляется в фоне после теста
+	stats, err := repo.UserStats(context.Background(), testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.UserID == "" {
+		t.Error("Leaderboard не обновлен после теста")
+	}
+}
+
+func TestQuizFlowRejectsStaleAnswer(t *testing.T) {
+	repo := newQuizRepository()
+	a, r, _ := newTestApp(t, repo)
+
+	press(t, r, "select_Тест")
+	session, _ := a.sessions.Get(SessionKey{ChatID: testChatID, UserID: testUserID})
+	stale := questionCallbackData("answer_", session, 0, 2)
+	press(t, r, stale)
+	press(t, r, stale) // повторное нажатие на уже отвеченный вопрос
+
+	session, ok := a.sessions.Get(SessionKey{ChatID: testChatID, UserID: testUserID})
+	if !ok || session.Position != 1 || len(session.Answers) != 1 {
+		t.Fatalf("после повторного нажатия попытка = %+v", session)
+	}
+}
+
+func TestAbandonedAttemptCountsTowardsLimit(t *testing.T) {
+	repo := newQuizRepository()
+	repo.SetTestSettings("Тест", [][]interface{}{{"max_attempts", "1"}})
+	a, r, telegram := newTestApp(t, repo)
+
+	press(t, r, "select_Тест")
+	answer(t, a, r, 1)
+	press(t, r, "abandon_test")
+
+	attempts, err := repo.Attempts(context.Background(), AttemptQuery{UserID: testUserID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 1 || !attempts[0].Interrupted || attempts[0].Score != 0 {
+		t.Fatalf("история после прерывания = %+v", attempts)
+	}
+	if len(repo.Results("Тест")) != 0 {
+		t.Error("прерванная попытка попала в результаты теста")
+	}
+
+	press(t, r, "select_Тест")
+	if _, ok := a.sessions.Get(SessionKey{ChatID: testChatID, UserID: testUserID}); ok {
+		t.Error("после прерванной попытки удалось начать тест сверх лимита")
+	}
+	if !telegram.sentText("Вы использовали все попытки этого теста (1).") {
+		t.Errorf("нет отказа в новой попытке, отправлено: %q", telegram.Texts())
+	}
+}
+
+func TestReselectingTestCountsUnfinishedAttempt(t *testing.T) {
+	repo := newQuizRepository()
+	repo.SetTestSettings("Тест", [][]interface{}{{"max_attempts", "1"}})
+	a, r, _ := newTestApp(t, repo)
+
+	press(t, r, "select_Тест")
+	first, _ := a.sessions.Get(SessionKey{ChatID: testChatID, UserID: testUserID})
+	press(t, r, "select_Тест")
+
+	session, ok := a.sessions.Get(SessionKey{ChatID: testChatID, UserID: testUserID})
+	if !ok || session.AttemptID != first.AttemptID {
+		t.Error("незавершенная попытка заменена новой сверх лимита")
+	}
+}
+
+// send отправляет боту сообщение message от имени ученика
+func send(t *testing.T, r *router.Router, message *tgbotapi.Message) {
+	t.Helper()
+	message.MessageID = 2
+	message.From = &tgbotapi.User{ID: testUserID, UserName: "student"}
+	message.Chat = &tgbotapi.Chat{ID: testChatID}
+	if err := r.Handle(context.Background(), tgbotapi.Update{Message: message}); err != nil {
+		t.Fatalf("сообщение %q: %v", message.Text, err)
+	}
+}
+
+func TestTextQuestionIgnoresMessagesWithoutText(t *testing.T) {
+	repo := NewMemoryRepository()
+	repo.AddTest("Тест", [][]interface{}{
+		{"1", "Столица Франции?", "", "", "", "Париж"},
+		{"2", "2+2?", "", "", "", "4"},
+	})
+	a, r, telegram := newTestApp(t, repo)
+	key := SessionKey{ChatID: testChatID, UserID: testUserID}
+
+	press(t, r, "select_Тест")
+	tests := []struct {
+		name    string
+		message *tgbotapi.Message
+	}{
+		{name: "стикер", message: &tgbotapi.Message{Sticker: &tgbotapi.Sticker{FileID: "sticker"}}},
+		{name: "фото", message: &tgbotapi.Message{Photo: []tgbotapi.PhotoSize{{FileID: "photo"}}}},
+		{name: "пробелы", message: &tgbotapi.Message{Text: "  "}},
+	}
+	for _, tt := range tests {
+		send(t, r, tt.message)
+		session, ok := a.sessions.Get(key)
+		if !ok || session.Position != 0 || len(session.Answers) != 0 {
+			t.Fatalf("%s засчитан как ответ: попытка = %+v", tt.name, session)
+		}
+	}
+	if !telegram.sentText("Вопрос 1 ждет ответа текстом.") {
+		t.Errorf("нет подсказки, отправлено: %q", telegram.Texts())
+	}
+
+	send(t, r, &tgbotapi.Message{Text: "париж"})
+	session, ok := a.sessions.Get(key)
+	if !ok || session.Position != 1 || len(session.Answers) != 1 {
+		t.Fatalf("ответ текстом не принят: попытка = %+v", session)
+	}
+}
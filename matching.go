@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// CheckText сравнивает свободный ответ пользователя с допустимыми ответами вопроса.
+// Ответы сравниваются без учета регистра, пробелов по краям и повторных пробелов, буква "ё"
+// считается равной "е". Числовой допустимый ответ сравнивается с числом пользователя с точностью
+// NumericTolerance, в остальных допускается до TypoTolerance опечаток (расстояние Левенштейна).
+func (q TestQuestion) CheckText(answer string, settings TestSettings) bool {
+	given := normalizeAnswer(answer)
+	if given == "" {
+		return false
+	}
+	givenNumber, givenIsNumber := parseNumberAnswer(given)
+
+	for _, accepted := range q.AcceptedAnswers {
+		expected := normalizeAnswer(accepted)
+
+		// Числовой ответ принимается только числом: опечатки в числах не допускаются,
+		// иначе "-" или "6" сошли бы за "5" при TypoTolerance = 1
+		if expectedNumber, ok := parseNumberAnswer(expected); ok {
+			if givenIsNumber && math.Abs(givenNumber-expectedNumber) <= settings.NumericTolerance {
+				return true
+			}
+			continue
+		}
+
+		if given == expected {
+			return true
+		}
+		if settings.TypoTolerance > 0 && levenshtein(given, expected) <= settings.TypoTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeAnswer приводит ответ к виду для сравнения
+func normalizeAnswer(text string) string {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	return strings.ReplaceAll(text, "ё", "е")
+}
+
+// parseNumberAnswer разбирает числовой ответ; допускается десятичная запятая
+func parseNumberAnswer(text string) (float64, bool) {
+	for _, r := range text {
+		if !unicode.IsDigit(r) && !strings.ContainsRune("+-.,", r) {
+			return 0, false
+		}
+	}
+	number, err := parsePoints(text)
+	return number, err == nil
+}
+
+// levenshtein возвращает расстояние Левенштейна между строками (по символам, а не байтам)
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,106 @@
+package main
+
+import "testing"
+
+func TestCheckText(t *testing.T) {
+	tests := []struct {
+		name     string
+		accepted []string
+		answer   string
+		settings TestSettings
+		want     bool
+	}{
+		{"точный ответ", []string{"Париж"}, "Париж", TestSettings{}, true},
+		{"регистр и пробелы", []string{"Новый Орлеан"}, "  новый   орлеан ", TestSettings{}, true},
+		{"ё равна е", []string{"ёлка"}, "елка", TestSettings{}, true},
+		{"е равна ё", []string{"елка"}, "Ёлка", TestSettings{}, true},
+		{"любой из допустимых", []string{"Москва", "Moscow"}, "moscow", TestSettings{}, true},
+		{"пустой ответ", []string{"Париж"}, "   ", TestSettings{}, false},
+		{"неверный ответ", []string{"Париж"}, "Лондон", TestSettings{}, false},
+		{"опечатка без допуска", []string{"Париж"}, "Парж", TestSettings{}, false},
+		{"опечатка в пределах допуска", []string{"Париж"}, "Парж", TestSettings{TypoTolerance: 1}, true},
+		{"перестановка — две опечатки", []string{"Париж"}, "Паирж", TestSettings{TypoTolerance: 1}, false},
+		{"опечаток больше допуска", []string{"Париж"}, "Пурыж", TestSettings{TypoTolerance: 1}, false},
+		{"опечатки в кириллице считаются по символам", []string{"кот"}, "кит", TestSettings{TypoTolerance: 1}, true},
+		{"равные числа", []string{"42"}, "42", TestSettings{}, true},
+		{"число с десятичной запятой", []string{"3.14"}, "3,14", TestSettings{}, true},
+		{"число с десятичной точкой", []string{"3,14"}, "3.14", TestSettings{}, true},
+		{"число с незначащими нулями", []string{"2.5"}, "2.50", TestSettings{}, true},
+		{"число со знаком", []string{"-7"}, "-7", TestSettings{}, true},
+		{"число в пределах точности", []string{"3.14"}, "3.141", TestSettings{NumericTolerance: 0.01}, true},
+		{"число вне точности", []string{"3.14"}, "3.2", TestSettings{NumericTolerance: 0.01}, false},
+		{"числа без точности сравниваются точно", []string{"3.14"}, "3.141", TestSettings{}, false},
+		{"опечатка в числе не допускается", []string{"5"}, "6", TestSettings{TypoTolerance: 1}, false},
+		{"знак вместо числа", []string{"5"}, "-", TestSettings{TypoTolerance: 1}, false},
+		{"плюс вместо числа", []string{"5"}, "+", TestSettings{TypoTolerance: 1}, false},
+		{"текст вместо числа", []string{"5"}, "пять", TestSettings{TypoTolerance: 3}, false},
+		{"знаки как текстовый ответ", []string{"+-"}, "+-", TestSettings{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			question := TestQuestion{Type: QuestionText, AcceptedAnswers: tt.accepted}
+			if got := question.CheckText(tt.answer, tt.settings); got != tt.want {
+				t.Errorf("CheckText(%q) с ответами %q = %v, ожидалось %v", tt.answer, tt.accepted, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNumberAnswer(t *testing.T) {
+	tests := []struct {
+		text   string
+		want   float64
+		wantOK bool
+	}{
+		{"42", 42, true},
+		{"-7", -7, true},
+		{"+3", 3, true},
+		{"1.5", 1.5, true},
+		{"1,5", 1.5, true},
+		{"-0,25", -0.25, true},
+		{"-", 0, false},
+		{"+", 0, false},
+		{"--", 0, false},
+		{"+-", 0, false},
+		{".", 0, false},
+		{",", 0, false},
+		{"1,5,0", 0, false},
+		{"12-3", 0, false},
+		{"1e5", 0, false},
+		{"inf", 0, false},
+		{"NaN", 0, false},
+		{"0x10", 0, false},
+		{"1 000", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseNumberAnswer(tt.text)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("parseNumberAnswer(%q) = %v, %v; ожидалось %v, %v", tt.text, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"париж", "париж", 0},
+		{"париж", "парж", 1},
+		{"ёж", "еж", 1},
+		{"кот", "ток", 2},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, ожидалось %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := levenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, ожидалось %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
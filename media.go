@@ -0,0 +1,97 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxCaptionLength — ограничение Telegram на длину подписи к медиа
+const maxCaptionLength = 1024
+
+// fileReference превращает значение ячейки в файл для отправки: ссылку или file_id Telegram
+func fileReference(value string) tgbotapi.RequestFileData {
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+		return tgbotapi.FileURL(value)
+	}
+	return tgbotapi.FileID(value)
+}
+
+// questionMedia возвращает медиа вопроса в порядке отправки: фото, видео, аудио
+func questionMedia(question TestQuestion) []tgbotapi.Chattable {
+	var media []tgbotapi.Chattable
+	if question.Photo != "" {
+		media = append(media, tgbotapi.NewPhoto(0, fileReference(question.Photo)))
+	}
+	if question.Video != "" {
+		media = append(media, tgbotapi.NewVideo(0, fileReference(question.Video)))
+	}
+	if question.Audio != "" {
+		media = append(media, tgbotapi.NewAudio(0, fileReference(question.Audio)))
+	}
+	return media
+}
+
+// sendQuestionMessage отправляет вопрос: если у вопроса есть медиа, текст вопроса становится
+// подписью к последнему медиа, а клавиатура прикрепляется к нему же. Если подпись слишком
+// длинная или медиа не удалось отправить, вопрос отправляется отдельным текстовым сообщением.
+func (a *app) sendQuestionMessage(chatID int64, question TestQuestion, text string, markup interface{}) (tgbotapi.Message, error) {
+	media := questionMedia(question)
+	captionFits := utf8.RuneCountInString(text) <= maxCaptionLength
+
+	for i, item := range media {
+		last := i == len(media)-1 && captionFits
+		var caption string
+		var itemMarkup interface{}
+		if last {
+			caption, itemMarkup = text, markup
+		}
+
+		switch m := item.(type) {
+		case tgbotapi.PhotoConfig:
+			m.ChatID, m.Caption, m.ReplyMarkup = chatID, caption, itemMarkup
+			item = m
+		case tgbotapi.VideoConfig:
+			m.ChatID, m.Caption, m.ReplyMarkup = chatID, caption, itemMarkup
+			item = m
+		case tgbotapi.AudioConfig:
+			m.ChatID, m.Caption, m.ReplyMarkup = chatID, caption, itemMarkup
+			item = m
+		}
+
+		sent, err := a.bot.Send(item)
+		if err != nil {
+			log.Printf("Не удалось отправить медиа вопроса %s: %v. Отправка только текста.", question.ID, err)
+			continue
+		}
+		if last {
+			return sent, nil
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = markup
+	return a.bot.Send(msg)
+}
+
+// editQuestionMessage заменяет текст сообщения с вопросом и убирает клавиатуру.
+// У сообщения с медиа меняется подпись.
+func (a *app) editQuestionMessage(chatID int64, messageID int, text string) error {
+	noKeyboard := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	editMsg.ReplyMarkup = &noKeyboard
+	if _, err := a.bot.Send(editMsg); err == nil {
+		return nil
+	}
+
+	if utf8.RuneCountInString(text) > maxCaptionLength {
+		text = string([]rune(text)[:maxCaptionLength-1]) + "…"
+	}
+	editCaption := tgbotapi.NewEditMessageCaption(chatID, messageID, text)
+	editCaption.ReplyMarkup = &noKeyboard
+	_, err := a.bot.Send(editCaption)
+	return err
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// MemoryRepository хранит все данные в памяти процесса.
+// Вопросы задаются строками в формате вкладки теста (по умолчанию A: ID, B: вопрос, C-E: варианты,
+// F: номер ответа), поэтому разбираются тем же кодом, что и данные из Google Sheets.
+type MemoryRepository struct {
+	mu     sync.Mutex
+	layout questionLayout
+	// settingsRange — диапазон настроек по умолчанию: по нему ошибки указывают адрес ячейки
+	settingsRange a1Range
+	testsSheet    string
+	testNames     []string
+	testRows      map[string][][]interface{}
+	settings      map[string][][]interface{}
+	catalog       [][]interface{}
+	results       map[string][]TestResult
+	attempts      []Attempt
+	leaderboard   []UserStats
+	teacher       TeacherProfile
+}
+
+// NewMemoryRepository создает пустой репозиторий в памяти с раскладкой колонок по умолчанию
+func NewMemoryRepository() *MemoryRepository {
+	cfg := defaultConfig().Sheets
+	layout, err := cfg.questionLayout()
+	if err != nil {
+		panic(err)
+	}
+	settingsRange, err := parseA1Range(cfg.SettingsRange)
+	if err != nil {
+		panic(err)
+	}
+	return &MemoryRepository{
+		layout:        layout,
+		settingsRange: settingsRange,
+		testsSheet:    cfg.TestsSheet,
+		testRows:      make(map[string][][]interface{}),
+		settings:      make(map[string][][]interface{}),
+		results:       make(map[string][]TestResult),
+	}
+}
+
+// AddTest добавляет (или заменяет) тест с вопросами в формате строк вкладки
+func (m *MemoryRepository) AddTest(testName string, rows [][]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.testRows[testName]; !ok {
+		m.testNames = append(m.testNames, testName)
+	}
+	m.testRows[testName] = rows
+}
+
+// SetTestSettings задает настройки теста строками "параметр | значение", как в диапазоне настроек вкладки
+func (m *MemoryRepository) SetTestSettings(testName string, rows [][]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.settings[testName] = rows
+}
+
+// SetTestCatalog задает описания тестов строками вкладки Tests (см. catalogHeader)
+func (m *MemoryRepository) SetTestCatalog(rows [][]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.catalog = rows
+}
+
+// SetTeacherProfile задает информацию о преподавателе
+func (m *MemoryRepository) SetTeacherProfile(profile TeacherProfile) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.teacher = profile
+}
+
+// Results возвращает сохраненные результаты теста
+func (m *MemoryRepository) Results(testName string) []TestResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]TestResult(nil), m.results[testName]...)
+}
+
+func (m *MemoryRepository) TestNames(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.testNames...), nil
+}
+
+func (m *MemoryRepository) TestCatalog(ctx context.Context) (TestCatalog, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	catalog := buildCatalog(m.testNames, sheetTable{Sheet: m.testsSheet, Range: catalogRange(), Rows: m.catalog})
+	logCellErrors(catalog.Problems)
+	return catalog, nil
+}
+
+func (m *MemoryRepository) LoadTest(ctx context.Context, testName string) (Test, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rows, ok := m.testRows[testName]
+	if !ok || len(rows) == 0 {
+		return Test{}, fmt.Errorf("во вкладке %s не найдено вопросов", testName)
+	}
+	test := parseTest(testName,
+		sheetTable{Sheet: testName, Range: m.layout.Range, Rows: rows},
+		sheetTable{Sheet: testName, Range: m.settingsRange, Rows: m.settings[testName]},
+		m.layout)
+	logCellErrors(test.Problems)
+	return test, nil
+}
+
+func (m *MemoryRepository) SaveResult(ctx context.Context, result TestResult, counting AttemptCounting) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := m.results[result.TestName]
+	for i, previous := range results {
+		if previous.UserID == result.UserID {
+			if counting.replaces(previous.Score, result.Score) {
+				results[i] = result
+			}
+			return nil
+		}
+	}
+	m.results[result.TestName] = append(results, result)
+	return nil
+}
+
+func (m *MemoryRepository) SaveAttempt(ctx context.Context, attempt Attempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.attempts = append(m.attempts, attempt)
+	return nil
+}
+
+func (m *MemoryRepository) Attempts(ctx context.Context, query AttemptQuery) ([]Attempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return selectAttempts(m.attempts, query), nil
+}
+
+func (m *MemoryRepository) UpdateLeaderboard(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var all []TestResult
+	counting := make(map[string]AttemptCounting)
+	for testName, results := range m.results {
+		all = append(all, results...)
+		settings, _ := parseTestSettings(sheetTable{Sheet: testName, Range: m.settingsRange, Rows: m.settings[testName]})
+		counting[testName] = settings.CountedAttempt
+	}
+	m.leaderboard = aggregateLeaderboard(all, counting)
+	return nil
+}
+
+func (m *MemoryRepository) UserStats(ctx context.Context, userID int64) (UserStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userIDStr := strconv.FormatInt(userID, 10)
+	for _, stats := range m.leaderboard {
+		if stats.UserID == userIDStr {
+			return stats, nil
+		}
+	}
+	return UserStats{}, nil
+}
+
+func (m *MemoryRepository) TeacherProfile(ctx context.Context) (TeacherProfile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.teacher, nil
+}
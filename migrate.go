@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// runMigrateResults переносит результаты тестов из старой раскладки (UserID, Username, "score/total",
+// время и длительность в колонках H:K или H:L) в колонки схемы resultSchemaVersion из sheets.results_range:
+//
+//	migrate-results [-results H2:L] [-settings M2:N] [класс]
+//
+// Старая раскладка занимала колонки, где теперь лежат результаты, поэтому настройки теста
+// переносятся из старого диапазона -settings в sheets.settings_range. Уже перенесенные тесты
+// не изменяются, и команду можно запускать повторно. Без указания класса переносятся все классы.
+func runMigrateResults(ctx context.Context, cfg Config, args []string) error {
+	flags := flag.NewFlagSet("migrate-results", flag.ContinueOnError)
+	oldResults := flags.String("results", "H2:L", "диапазон результатов в старой раскладке")
+	oldSettings := flags.String("settings", "M2:N", "диапазон настроек в старой раскладке")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("использование: migrate-results [-results H2:L] [-settings M2:N] [класс]")
+	}
+	for _, value := range []string{*oldResults, *oldSettings} {
+		if _, err := parseA1Range(value); err != nil {
+			return err
+		}
+	}
+
+	found := false
+	for _, tenant := range cfg.TenantConfigs() {
+		if flags.NArg() == 1 && tenant.ID != flags.Arg(0) {
+			continue
+		}
+		found = true
+		log.Printf("Перенос результатов класса %s", tenant.ID)
+		if err := migrateTenantResults(ctx, cfg.ForTenant(tenant), *oldResults, *oldSettings); err != nil {
+			return fmt.Errorf("класс %s: %w", tenant.ID, err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("класс %q не найден в настройках", flags.Arg(0))
+	}
+	return nil
+}
+
+// migrateTenantResults переносит результаты всех тестов таблицы одного класса
+func migrateTenantResults(ctx context.Context, cfg Config, oldResults, oldSettings string) error {
+	if err := cfg.Sheets.Validate(); err != nil {
+		return err
+	}
+	service, err := newSheetsService(ctx, cfg.Sheets.CredentialsFile)
+	if err != nil {
+		return err
+	}
+	repo, err := NewSheetsRepository(service, cfg.Sheets)
+	if err != nil {
+		return err
+	}
+
+	names, err := repo.TestNames(ctx)
+	if err != nil {
+		return err
+	}
+	// По истории попыток восстанавливаются номера попыток и баллы, которые Sheets превратил в даты
+	attempts, err := repo.Attempts(ctx, AttemptQuery{})
+	if err != nil {
+		return err
+	}
+	// Тест, который перенести не удалось, не мешает переносу остальных
+	var failed []error
+	for _, name := range names {
+		if err := repo.migrateTestResults(ctx, name, oldResults, oldSettings, attempts); err != nil {
+			log.Printf("Перенос не выполнен: %v", err)
+			failed = append(failed, err)
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// migrateTestResults переносит результаты и настройки одного теста. Тест, в котором уже есть
+// строки новой схемы, пропускается. Если хотя бы одну строку восстановить не удалось, вкладка
+// не изменяется: по каждой такой строке печатается отчет, а возвращается ошибка.
+func (r *SheetsRepository) migrateTestResults(ctx context.Context, testName, oldResults, oldSettings string, attempts []Attempt) error {
+	values, err := r.batchGet(ctx,
+		fmt.Sprintf("%s!%s", testName, oldResults),
+		fmt.Sprintf("%s!%s", testName, oldSettings),
+		fmt.Sprintf("%s!%s", testName, r.cfg.ResultsRange),
+		fmt.Sprintf("%s!%s", testName, r.cfg.SettingsRange),
+	)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать результаты вкладки %s: %w", testName, err)
+	}
+	oldRows, oldSettingRows := values[0], values[1]
+	currentRows, settingRows := values[2], values[3]
+
+	for _, row := range currentRows {
+		if resultRowVersion(row) >= resultSchemaVersion {
+			log.Printf("Тест %s: результаты уже перенесены", testName)
+			return nil
+		}
+	}
+	// Настройки переносятся, только если в новом диапазоне их еще нет
+	moveSettings := oldSettings != r.cfg.SettingsRange && len(settingRows) == 0 && len(oldSettingRows) > 0
+	if moveSettings {
+		settingRows = oldSettingRows
+	}
+	if len(oldRows) == 0 && !moveSettings {
+		return nil
+	}
+	settings, _ := parseTestSettings(sheetTable{Sheet: testName, Range: r.settings, Rows: settingRows})
+
+	old, _ := parseA1Range(oldResults)
+	oldTable := sheetTable{Sheet: testName, Range: old, Rows: oldRows}
+	var newRows [][]interface{}
+	unrecovered := 0
+	for i, row := range oldRows {
+		if cellText(row, 0) == "" {
+			continue
+		}
+		result, problems := migrateResultRow(oldTable.decoder(i), settings, attempts)
+		if problems != nil {
+			unrecovered++
+			log.Printf("Тест %s: строка %d не перенесена: %v", testName, old.StartRow+i, row)
+			logCellErrors(problems)
+			continue
+		}
+		newRows = append(newRows, resultRow(result))
+	}
+	// Старый диапазон очищается только вместе с переносом всех строк, иначе результаты пропали бы
+	if unrecovered > 0 {
+		return fmt.Errorf("вкладка %s: не удалось восстановить строк — %d; исправьте их и запустите перенос снова", testName, unrecovered)
+	}
+
+	clearRanges := []string{
+		fmt.Sprintf("%s!%s", testName, oldResults),
+		fmt.Sprintf("%s!%s", testName, r.cfg.ResultsRange),
+	}
+	if moveSettings {
+		clearRanges = append(clearRanges, fmt.Sprintf("%s!%s", testName, oldSettings))
+	}
+	clear := &sheets.BatchClearValuesRequest{Ranges: clearRanges}
+	if _, err := r.service.Spreadsheets.Values.BatchClear(r.spreadsheetID, clear).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("не удалось очистить результаты вкладки %s: %w", testName, err)
+	}
+
+	update := &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "RAW",
+		Data: []*sheets.ValueRange{
+			{Range: fmt.Sprintf("%s!%s", testName, r.results.Cell(0)), Values: newRows},
+		},
+	}
+	if header, ok := r.resultsHeaderRange(testName); ok {
+		update.Data = append(update.Data, &sheets.ValueRange{Range: header, Values: [][]interface{}{resultsHeader}})
+	}
+	if moveSettings {
+		update.Data = append(update.Data, &sheets.ValueRange{Range: fmt.Sprintf("%s!%s", testName, r.settings.Cell(0)), Values: settingRows})
+	}
+	if _, err := r.service.Spreadsheets.Values.BatchUpdate(r.spreadsheetID, update).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("не удалось записать результаты вкладки %s: %w", testName, err)
+	}
+	log.Printf("Тест %s: перенесено результатов — %d, настройки перенесены: %t", testName, len(newRows), moveSettings)
+	return nil
+}
+
+// migrateResultRow разбирает строку старой раскладки. Балл, который Sheets превратил в дату, decodeResult
+// восстанавливает по дате; если балл не разобрать и так, он берется из попытки пользователя, завершенной
+// в то же время. Номер попытки считается по истории. Если строку восстановить не удалось, возвращает
+// ошибки разбора.
+func migrateResultRow(d *rowDecoder, settings TestSettings, attempts []Attempt) (TestResult, []error) {
+	testName := d.table.Sheet
+	result, ok := decodeResult(d)
+	if !ok {
+		recovery := d.table.decoder(d.index)
+		userID := recovery.Int64(0, "UserID")
+		finishedAt := recovery.Time(3, "Завершен")
+		attempt, found := findAttempt(attempts, testName, userID, finishedAt)
+		if !recovery.OK() || finishedAt.IsZero() || !found {
+			return TestResult{}, d.Errors()
+		}
+		result = attempt.Result()
+		result.Username = recovery.Text(1)
+	}
+
+	result.Passed = settings.Passed(result.Score, result.MaxScore)
+	for _, attempt := range attempts {
+		if attempt.TestName == testName && attempt.UserID == result.UserID && !attempt.FinishedAt.After(result.FinishedAt) {
+			result.Attempt++
+		}
+	}
+	return result, nil
+}
+
+// findAttempt ищет попытку пользователя в тесте, завершенную в момент finishedAt (с точностью до секунды)
+func findAttempt(attempts []Attempt, testName string, userID int64, finishedAt time.Time) (Attempt, bool) {
+	for _, attempt := range attempts {
+		if !attempt.Interrupted && attempt.TestName == testName && attempt.UserID == userID && attempt.FinishedAt.Truncate(time.Second).Equal(finishedAt) {
+			return attempt, true
+		}
+	}
+	return Attempt{}, false
+}
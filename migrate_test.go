@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// dateSerial возвращает дату так, как ее хранит Sheets: число дней с 30.12.1899
+func dateSerial(year int, month time.Month, day int) float64 {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Sub(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)).Hours() / 24
+}
+
+// legacyResultsTable возвращает строки результатов старой раскладки в диапазоне H2:L
+func legacyResultsTable(t *testing.T, rows ...[]interface{}) sheetTable {
+	t.Helper()
+	old, err := parseA1Range("H2:L")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sheetTable{Sheet: "Тест", Range: old, Rows: rows}
+}
+
+func TestMigrateResultRow(t *testing.T) {
+	finished := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	attempts := []Attempt{
+		{TestName: "Тест", UserID: 200, Score: 1, MaxScore: 5, FinishedAt: finished.Add(-time.Hour)},
+		{TestName: "Тест", UserID: 200, Score: 4, MaxScore: 5, Passed: true, FinishedAt: finished, Duration: 2 * time.Minute},
+		{TestName: "Тест", UserID: 200, Score: 5, MaxScore: 5, FinishedAt: finished.Add(time.Hour)},
+	}
+	settings := TestSettings{PassPercent: 60}
+
+	tests := []struct {
+		name      string
+		score     interface{}
+		wantScore float64
+		wantMax   float64
+	}{
+		{name: "текст 3/5", score: "3/5", wantScore: 3, wantMax: 5},
+		{name: "дробный балл", score: "2.5/5", wantScore: 2.5, wantMax: 5},
+		{name: "дата 5 марта", score: dateSerial(2026, time.March, 5), wantScore: 3, wantMax: 5},
+		// В таблице с русским языком "3/5" становится 3 мая
+		{name: "дата 3 мая", score: dateSerial(2026, time.May, 3), wantScore: 3, wantMax: 5},
+		{name: "дата 5 мая", score: dateSerial(2026, time.May, 5), wantScore: 5, wantMax: 5},
+		// Балл не разобрать, но попытка в то же время есть в истории
+		{name: "текст из истории", score: "четыре", wantScore: 4, wantMax: 5},
+		{name: "дата со временем из истории", score: dateSerial(2026, time.March, 5) + 0.5, wantScore: 4, wantMax: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := legacyResultsTable(t, []interface{}{200.0, "student", tt.score, "2026-10-01 12:00:00", "2m0s"})
+			result, problems := migrateResultRow(table.decoder(0), settings, attempts)
+			if problems != nil {
+				t.Fatalf("строка не восстановлена: %v", problems)
+			}
+			if result.Score != tt.wantScore || result.MaxScore != tt.wantMax {
+				t.Errorf("результат %v/%v, ожидалось %v/%v", result.Score, result.MaxScore, tt.wantScore, tt.wantMax)
+			}
+			// Зачет пересчитывается по настройкам теста, номер попытки — по истории
+			if wantPassed := settings.Passed(tt.wantScore, tt.wantMax); result.Passed != wantPassed {
+				t.Errorf("зачет %t, ожидался %t", result.Passed, wantPassed)
+			}
+			if result.Attempt != 2 || result.Username != "student" || !result.FinishedAt.Equal(finished) {
+				t.Errorf("результат = %+v", result)
+			}
+		})
+	}
+}
+
+func TestMigrateResultRowUnrecoverable(t *testing.T) {
+	tests := []struct {
+		name     string
+		row      []interface{}
+		wantCell string
+	}{
+		{name: "балл не разобрать, истории нет", row: []interface{}{200.0, "student", "четыре", "2026-10-01 12:00:00"}, wantCell: "J2"},
+		{name: "число вместо балла", row: []interface{}{200.0, "student", 3.0, "2026-10-01 12:00:00"}, wantCell: "J2"},
+		{name: "неверное время", row: []interface{}{200.0, "student", "3/5", "вчера"}, wantCell: "K2"},
+		{name: "неверный UserID", row: []interface{}{"ученик", "student", "3/5", "2026-10-01 12:00:00"}, wantCell: "H2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := legacyResultsTable(t, tt.row)
+			_, problems := migrateResultRow(table.decoder(0), TestSettings{}, nil)
+			if problems == nil {
+				t.Fatal("строка восстановлена")
+			}
+			var cellErr *CellError
+			if !errors.As(problems[0], &cellErr) || cellErr.Cell != tt.wantCell {
+				t.Errorf("ошибки %v, ожидалась ошибка в ячейке %s", problems, tt.wantCell)
+			}
+		})
+	}
+}
+
+// legacySheets возвращает вкладку теста в старой раскладке: результаты в H2:L, настройки в M2:N
+func legacySheets(rows ...[]interface{}) map[string][][]interface{} {
+	return map[string][][]interface{}{
+		"Тест!H2:L": rows,
+		"Тест!M2:N": {{"pass_percent", 60.0}},
+		"Тест!H2:Q": nil,
+		"Тест!S2:T": nil,
+	}
+}
+
+func TestSheetsMigrateTestResults(t *testing.T) {
+	fake := newFakeSheets(legacySheets(
+		[]interface{}{200.0, "student", "3/5", "2026-10-01 12:00:00", "2m0s"},
+		[]interface{}{300.0, "other", dateSerial(2026, time.February, 5), "2026-10-01 13:00:00", "1m0s"},
+	))
+	repo := newFakeSheetsRepository(t, fake)
+
+	if err := repo.migrateTestResults(context.Background(), "Тест", "H2:L", "M2:N", nil); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, row := range fake.values["Тест!H2"] {
+		got = append(got, fmt.Sprint(row[:6]))
+	}
+	want := []string{"[200 student 3 5 60 да]", "[300 other 2 5 40 нет]"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("перенесенные результаты = %q, ожидалось %q", got, want)
+	}
+	if rows := fake.values["Тест!H2:L"]; rows != nil {
+		t.Errorf("старый диапазон не очищен: %v", rows)
+	}
+	if settings := fake.values["Тест!S2"]; fmt.Sprint(settings) != "[[pass_percent 60]]" {
+		t.Errorf("настройки = %v", settings)
+	}
+}
+
+func TestSheetsMigrateTestResultsKeepsUnrecoveredRows(t *testing.T) {
+	rows := [][]interface{}{
+		{200.0, "student", "3/5", "2026-10-01 12:00:00", "2m0s"},
+		{300.0, "other", "три из пяти", "2026-10-01 13:00:00", "1m0s"},
+	}
+	fake := newFakeSheets(legacySheets(rows...))
+	repo := newFakeSheetsRepository(t, fake)
+
+	if err := repo.migrateTestResults(context.Background(), "Тест", "H2:L", "M2:N", nil); err == nil {
+		t.Fatal("ошибка переноса не возвращена")
+	}
+	// Вкладка не изменяется: ни одна строка не пропала и ничего не записано
+	if got := fake.values["Тест!H2:L"]; len(got) != len(rows) {
+		t.Errorf("старый диапазон = %v", got)
+	}
+	if _, ok := fake.values["Тест!H2"]; ok {
+		t.Error("результаты записаны, хотя одну строку восстановить не удалось")
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Repository описывает хранилище данных бота: тесты, результаты, Leaderboard и профиль преподавателя.
+// Основная реализация работает с Google Sheets, для тестов есть реализация в памяти.
+type Repository interface {
+	// TestNames возвращает названия доступных тестов
+	TestNames(ctx context.Context) ([]string, error)
+	// TestCatalog возвращает все тесты с описаниями из вкладки Tests в порядке показа ученикам
+	TestCatalog(ctx context.Context) (TestCatalog, error)
+	// LoadTest загружает вопросы и настройки теста
+	LoadTest(ctx context.Context, testName string) (Test, error)
+	// SaveResult сохраняет засчитываемый результат пользователя в тесте по правилу counting:
+	// лучший заменяет предыдущий, только если он выше; первый не заменяется; последний и средний
+	// (средний балл считает вызывающий) заменяют предыдущий всегда
+	SaveResult(ctx context.Context, result TestResult, counting AttemptCounting) error
+	// SaveAttempt добавляет завершенную попытку с ответами в историю попыток
+	SaveAttempt(ctx context.Context, attempt Attempt) error
+	// Attempts возвращает попытки из истории, подходящие под запрос, от новых к старым
+	Attempts(ctx context.Context, query AttemptQuery) ([]Attempt, error)
+	// UpdateLeaderboard пересчитывает Leaderboard по результатам всех тестов
+	UpdateLeaderboard(ctx context.Context) error
+	// UserStats возвращает статистику пользователя из Leaderboard
+	UserStats(ctx context.Context, userID int64) (UserStats, error)
+	// TeacherProfile возвращает информацию о преподавателе
+	TeacherProfile(ctx context.Context) (TeacherProfile, error)
+}
+
+// Результат прохождения теста одним пользователем
+type TestResult struct {
+	TestName string
+	UserID   int64
+	Username string
+	// Score — набранные баллы, MaxScore — сколько баллов можно было набрать
+	Score    float64
+	MaxScore float64
+	// Passed — набран ли проходной процент теста
+	Passed     bool
+	FinishedAt time.Time
+	// Duration — сколько времени заняло прохождение теста
+	Duration time.Duration
+	// Attempt — номер попытки пользователя в этом тесте, с единицы (0 — неизвестен)
+	Attempt int
+}
+
+// Percent возвращает результат в процентах от максимального балла
+func (r TestResult) Percent() float64 {
+	return scorePercent(r.Score, r.MaxScore)
+}
+
+// scorePercent возвращает долю score от maxScore в процентах
+func scorePercent(score, maxScore float64) float64 {
+	if maxScore <= 0 {
+		return 0
+	}
+	return score / maxScore * 100
+}
+
+// Информация о преподавателе
+type TeacherProfile struct {
+	Name        string
+	Description string
+	Contacts    string
+	PhotoURL    string
+	AudioURL    string
+	VideoURL    string
+}
+
+// parseTestRows разбирает строки диапазона вопросов (ID, вопрос, от двух вариантов, номер ответа)
+// в вопросы по раскладке колонок layout. Вопрос без вариантов — вопрос со свободным ответом,
+// в колонке ответа тогда перечисляются допустимые ответы. Пустые строки пропускаются,
+// некорректные — тоже, а их ошибки возвращаются с адресом ячейки. Вопрос с ID, который уже
+// встречался выше, тоже пропускается: по ID ответы сохраняются в историю попыток.
+func parseTestRows(table sheetTable, layout questionLayout) ([]TestQuestion, []error) {
+	var testData []TestQuestion
+	var problems []error
+	// seen — адрес ячейки, где впервые встретился ID вопроса
+	seen := make(map[string]string)
+	for i := range table.Rows {
+		d := table.decoder(i)
+		if d.Empty() {
+			continue
+		}
+		question, ok := decodeQuestion(d, layout)
+		id := strings.TrimSpace(question.ID)
+		if first, duplicate := seen[id]; ok && duplicate {
+			d.Fail(layout.ID, "ID вопроса %q уже используется в ячейке %s", id, first)
+			ok = false
+		}
+		if ok {
+			seen[id] = d.Cell(layout.ID)
+			testData = append(testData, question)
+		}
+		problems = append(problems, d.Errors()...)
+	}
+	return testData, problems
+}
+
+// decodeQuestion разбирает одну строку вопроса
+func decodeQuestion(d *rowDecoder, layout questionLayout) (TestQuestion, bool) {
+	answer := d.Text(layout.Answer)
+	typeText := d.Text(layout.Type)
+	question := TestQuestion{
+		ID:          d.RequiredText(layout.ID, "ID"),
+		Question:    d.RequiredText(layout.Question, "вопрос"),
+		Explanation: d.Text(layout.Explanation),
+		Photo:       strings.TrimSpace(d.Text(layout.Photo)),
+		Audio:       strings.TrimSpace(d.Text(layout.Audio)),
+		Video:       strings.TrimSpace(d.Text(layout.Video)),
+	}
+	// Пустая колонка веса — один балл
+	if strings.TrimSpace(d.Text(layout.Points)) != "" {
+		question.Points = d.Float(layout.Points, "вес")
+		if question.Points <= 0 && d.OK() {
+			d.Fail(layout.Points, "вес вопроса должен быть положительным числом, получено %s", formatPoints(question.Points))
+		}
+	}
+
+	if isTextQuestion(d.row, layout, typeText) {
+		question.Type = QuestionText
+		question.AcceptedAnswers = parseAcceptedAnswers(answer)
+		if len(question.AcceptedAnswers) == 0 {
+			d.Fail(layout.Answer, "не указаны допустимые ответы на вопрос со свободным ответом (через ;)")
+		}
+		return question, d.OK()
+	}
+
+	options, missing := parseOptions(d.row, layout)
+	if missing >= 0 {
+		d.Fail(missing, "должно быть не меньше двух вариантов ответа без пропусков")
+		return TestQuestion{}, false
+	}
+	question.Options = options
+
+	correct, ok := parseAnswerNumbers(answer, len(options))
+	if !ok {
+		d.Fail(layout.Answer, "номер верного ответа должен быть от 1 до %d (несколько — через запятую), получено %q", len(options), answer)
+		return TestQuestion{}, false
+	}
+	question.CorrectAnswers = correct
+
+	if question.Type, ok = parseQuestionType(typeText, len(correct)); !ok {
+		d.Fail(layout.Type, "тип вопроса должен быть single, multi или text, получено %q", typeText)
+	}
+	return question, d.OK()
+}
+
+// isTextQuestion сообщает, что строка описывает вопрос со свободным ответом:
+// тип text указан явно или тип не указан и колонки вариантов пусты
+func isTextQuestion(row []interface{}, layout questionLayout, typeText string) bool {
+	switch QuestionType(strings.ToLower(strings.TrimSpace(typeText))) {
+	case QuestionText:
+		return true
+	case "":
+		for i := layout.OptionsFrom; i <= layout.OptionsTo; i++ {
+			if strings.TrimSpace(cellText(row, i)) != "" {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// parseAcceptedAnswers разбирает допустимые свободные ответы, перечисленные через ";"
+func parseAcceptedAnswers(text string) []string {
+	var accepted []string
+	for _, answer := range strings.Split(text, ";") {
+		if answer = strings.TrimSpace(answer); answer != "" {
+			accepted = append(accepted, answer)
+		}
+	}
+	return accepted
+}
+
+// parseOptions возвращает заполненные варианты ответа. Пустые колонки в конце диапазона вариантов
+// допускаются (у вопроса меньше вариантов), пропуск между вариантами — нет. Если варианты заданы
+// неверно, возвращает колонку первого пропущенного варианта, иначе -1.
+func parseOptions(row []interface{}, layout questionLayout) ([]string, int) {
+	var options []string
+	for i := layout.OptionsFrom; i <= layout.OptionsTo; i++ {
+		options = append(options, cellText(row, i))
+	}
+	for len(options) > 0 && strings.TrimSpace(options[len(options)-1]) == "" {
+		options = options[:len(options)-1]
+	}
+	for i, option := range options {
+		if strings.TrimSpace(option) == "" {
+			return nil, layout.OptionsFrom + i
+		}
+	}
+	if len(options) < 2 {
+		return nil, layout.OptionsFrom + len(options)
+	}
+	return options, -1
+}
+
+// parseAnswerNumbers разбирает номера верных вариантов: "2" или "1,3" (допускаются ";" и пробелы).
+// Точка тоже разделяет номера: Sheets в русской локали хранит "1,3" числом 1.3.
+// Номера должны быть от 1 до count и не повторяться; возвращаются по возрастанию.
+func parseAnswerNumbers(text string, count int) ([]int, bool) {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '.'
+	})
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	seen := make(map[int]bool)
+	var numbers []int
+	for _, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > count || seen[n] {
+			return nil, false
+		}
+		seen[n] = true
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+	return numbers, true
+}
+
+// parseQuestionType разбирает колонку типа вопроса. Пустая колонка означает single,
+// а при нескольких верных вариантах — multi.
+func parseQuestionType(text string, correctCount int) (QuestionType, bool) {
+	switch QuestionType(strings.ToLower(strings.TrimSpace(text))) {
+	case "":
+		if correctCount > 1 {
+			return QuestionMulti, true
+		}
+		return QuestionSingle, true
+	case QuestionSingle:
+		return QuestionSingle, correctCount == 1
+	case QuestionMulti:
+		return QuestionMulti, true
+	default:
+		return "", false
+	}
+}
+
+// cellText возвращает текст ячейки i строки; отсутствующие ячейки (Sheets API обрезает
+// пустые ячейки в конце строки) и незаданные колонки (i < 0) считаются пустыми
+func cellText(row []interface{}, i int) string {
+	if i < 0 || i >= len(row) || row[i] == nil {
+		return ""
+	}
+	switch value := row[i].(type) {
+	case string:
+		return value
+	case float64:
+		// Необработанные значения Sheets: числа приходят float64, печатаем без экспоненты
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// formatScore и parseScore переводят результат в текст колонки J ("score/max") и обратно.
+// Дробные баллы (частичный зачет, веса вопросов) записываются с точкой: "2.5/4".
+func formatScore(score, maxScore float64) string {
+	return fmt.Sprintf("%s/%s", formatPoints(score), formatPoints(maxScore))
+}
+
+func parseScore(text string) (score, maxScore float64, ok bool) {
+	scoreParts := strings.Split(text, "/")
+	if len(scoreParts) != 2 {
+		return 0, 0, false
+	}
+	score, err := parsePoints(scoreParts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	maxScore, _ = parsePoints(scoreParts[1])
+	return score, maxScore, true
+}
+
+// formatPoints печатает балл без лишних нулей, округляя до сотых: 3, 2.5, 0.67
+func formatPoints(points float64) string {
+	return strconv.FormatFloat(roundPoints(points), 'f', -1, 64)
+}
+
+// roundPoints округляет балл или процент до сотых
+func roundPoints(points float64) float64 {
+	return math.Round(points*100) / 100
+}
+
+// parsePoints разбирает балл; десятичная запятая (так Sheets показывает числа в русской локали) допускается
+func parsePoints(text string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(strings.TrimSpace(text), ",", ".", 1), 64)
+}
+
+// formatDuration и parseDuration переводят время прохождения в текст колонки результатов ("2m35s") и обратно
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+func parseDuration(text string) time.Duration {
+	d, _ := time.ParseDuration(strings.TrimSpace(text))
+	return d
+}
+
+// countedResult выбирает из результатов одного пользователя в одном тесте засчитываемый:
+// лучший, последний, первый или последний со средним баллом всех попыток
+func countedResult(counting AttemptCounting, results []TestResult) TestResult {
+	counted := results[0]
+	sum := 0.0
+	for _, result := range results {
+		sum += result.Score
+		switch counting {
+		case CountLatest, CountAverage:
+			if result.FinishedAt.After(counted.FinishedAt) {
+				counted = result
+			}
+		case CountFirst:
+			if result.FinishedAt.Before(counted.FinishedAt) {
+				counted = result
+			}
+		default:
+			if result.Score > counted.Score {
+				counted = result
+			}
+		}
+	}
+	if counting == CountAverage {
+		counted.Score = sum / float64(len(results))
+	}
+	return counted
+}
+
+// aggregateLeaderboard берет засчитываемый результат каждого пользователя в каждом тесте
+// (по правилу counting этого теста, по умолчанию лучший), суммирует баллы, считает тесты,
+// в которых засчитываемый результат сдан на зачет, и ранжирует пользователей по убыванию общего балла.
+func aggregateLeaderboard(results []TestResult, counting map[string]AttemptCounting) []UserStats {
+	userResults := make(map[string]map[string][]TestResult)
+	userNames := make(map[string]string)
+
+	for _, result := range results {
+		userIDStr := strconv.FormatInt(result.UserID, 10)
+		userNames[userIDStr] = result.Username
+
+		if _, ok := userResults[userIDStr]; !ok {
+			userResults[userIDStr] = make(map[string][]TestResult)
+		}
+		userResults[userIDStr][result.TestName] = append(userResults[userIDStr][result.TestName], result)
+	}
+
+	// Агрегация: суммируем засчитываемые баллы и считаем тесты, сданные на зачет
+	var aggregatedStats []UserStats
+	for userIDStr, resultsByTest := range userResults {
+		totalScore := 0.0
+		totalPassed := 0
+
+		for testName, testResults := range resultsByTest {
+			counted := countedResult(counting[testName], testResults)
+			totalScore += counted.Score
+			if counted.Passed {
+				totalPassed++
+			}
+		}
+
+		aggregatedStats = append(aggregatedStats, UserStats{
+			UserID:      userIDStr,
+			Username:    userNames[userIDStr],
+			TotalScore:  totalScore,
+			TotalPassed: totalPassed,
+		})
+	}
+
+	// Ранжирование по TotalScore (по убыванию)
+	sort.Slice(aggregatedStats, func(i, j int) bool {
+		if aggregatedStats[i].TotalScore != aggregatedStats[j].TotalScore {
+			return aggregatedStats[i].TotalScore > aggregatedStats[j].TotalScore
+		}
+		if aggregatedStats[i].TotalPassed != aggregatedStats[j].TotalPassed {
+			return aggregatedStats[i].TotalPassed > aggregatedStats[j].TotalPassed
+		}
+		return aggregatedStats[i].Username < aggregatedStats[j].Username
+	})
+
+	return aggregatedStats
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// defaultLayout возвращает раскладку колонок вопросов из настроек по умолчанию:
+// ID в A, вопрос в B, варианты в C:E, ответ в F
+func defaultLayout(t *testing.T) questionLayout {
+	t.Helper()
+	layout, err := defaultConfig().Sheets.questionLayout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return layout
+}
+
+func TestParseOptions(t *testing.T) {
+	layout := defaultLayout(t)
+	tests := []struct {
+		name        string
+		row         []interface{}
+		wantOptions []string
+		wantMissing int
+	}{
+		{name: "три варианта", row: []interface{}{"1", "?", "а", "б", "в", "1"}, wantOptions: []string{"а", "б", "в"}, wantMissing: -1},
+		{name: "пустые варианты в конце", row: []interface{}{"1", "?", "а", "б", "", "1"}, wantOptions: []string{"а", "б"}, wantMissing: -1},
+		{name: "строка обрезана после вариантов", row: []interface{}{"1", "?", "а", "б"}, wantOptions: []string{"а", "б"}, wantMissing: -1},
+		{name: "числовые варианты", row: []interface{}{"1", "?", 3.0, 4.0, 5.0, 2.0}, wantOptions: []string{"3", "4", "5"}, wantMissing: -1},
+		{name: "пропуск между вариантами", row: []interface{}{"1", "?", "а", " ", "в", "1"}, wantMissing: 3},
+		{name: "один вариант", row: []interface{}{"1", "?", "а", "", "", "1"}, wantMissing: 3},
+		{name: "нет вариантов", row: []interface{}{"1", "?"}, wantMissing: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, missing := parseOptions(tt.row, layout)
+			if !slices.Equal(options, tt.wantOptions) || missing != tt.wantMissing {
+				t.Errorf("parseOptions = %q, %d; ожидалось %q, %d", options, missing, tt.wantOptions, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestParseTestRows(t *testing.T) {
+	layout := defaultLayout(t)
+	rows := [][]interface{}{
+		{"1", "2+2?", 3.0, 4.0, "", 2.0},
+		{"2", "Что из этого столицы?", "Париж", "Лондон", "Рим", "1,3"},
+		{"3", "Столица Франции?", "", "", "", "Париж; Paris"},
+		{"1", "Повторный ID", "а", "б", "", "1"},
+		{"5", "Один вариант", "а", "", "", "1"},
+		{"6", "Неверный номер ответа", "а", "б", "", "3"},
+		{},
+		{"8", "", "а", "б", "", "1"},
+		{"9", "Свободный ответ без ответов", "", "", "", " ; "},
+	}
+	questions, problems := parseTestRows(sheetTable{Sheet: "Тест", Range: layout.Range, Rows: rows}, layout)
+
+	want := []TestQuestion{
+		{ID: "1", Question: "2+2?", Type: QuestionSingle, Options: []string{"3", "4"}, CorrectAnswers: []int{2}},
+		{ID: "2", Question: "Что из этого столицы?", Type: QuestionMulti, Options: []string{"Париж", "Лондон", "Рим"}, CorrectAnswers: []int{1, 3}},
+		{ID: "3", Question: "Столица Франции?", Type: QuestionText, AcceptedAnswers: []string{"Париж", "Paris"}},
+	}
+	if len(questions) != len(want) {
+		t.Fatalf("вопросы = %+v, ожидалось %d вопроса", questions, len(want))
+	}
+	for i, question := range questions {
+		w := want[i]
+		if question.ID != w.ID || question.Question != w.Question || question.Type != w.Type ||
+			!slices.Equal(question.Options, w.Options) || !slices.Equal(question.CorrectAnswers, w.CorrectAnswers) ||
+			!slices.Equal(question.AcceptedAnswers, w.AcceptedAnswers) {
+			t.Errorf("вопрос %d = %+v, ожидалось %+v", i+1, question, w)
+		}
+	}
+
+	// Строки с ошибками пропускаются, ошибки указывают ячейку
+	var cells []string
+	for _, problem := range problems {
+		var cellErr *CellError
+		if !errors.As(problem, &cellErr) || cellErr.Sheet != "Тест" {
+			t.Fatalf("ошибка без адреса ячейки: %v", problem)
+		}
+		cells = append(cells, cellErr.Cell)
+	}
+	if wantCells := []string{"A5", "D6", "F7", "B9", "F10"}; !slices.Equal(cells, wantCells) {
+		t.Errorf("ошибки в ячейках %v, ожидались %v: %v", cells, wantCells, problems)
+	}
+}
+
+func TestParseAnswerNumbers(t *testing.T) {
+	tests := []struct {
+		text   string
+		count  int
+		want   []int
+		wantOK bool
+	}{
+		{text: "2", count: 3, want: []int{2}, wantOK: true},
+		{text: "1,3", count: 3, want: []int{1, 3}, wantOK: true},
+		{text: "3; 1", count: 3, want: []int{1, 3}, wantOK: true},
+		{text: " 2 3 ", count: 3, want: []int{2, 3}, wantOK: true},
+		// Sheets в русской локали хранит "1,3" числом 1.3
+		{text: "1.3", count: 3, want: []int{1, 3}, wantOK: true},
+		{text: "", count: 3},
+		{text: "0", count: 3},
+		{text: "4", count: 3},
+		{text: "1,1", count: 3},
+		{text: "а", count: 3},
+		{text: "-1", count: 3},
+	}
+	for _, tt := range tests {
+		got, ok := parseAnswerNumbers(tt.text, tt.count)
+		if ok != tt.wantOK || !slices.Equal(got, tt.want) {
+			t.Errorf("parseAnswerNumbers(%q, %d) = %v, %v; ожидалось %v, %v", tt.text, tt.count, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCountedResult(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 12, 0, 0, 0, time.Local) }
+	// Результаты не по порядку: засчитываемый выбирается по баллу или времени, а не по положению
+	results := []TestResult{
+		{Score: 2, FinishedAt: day(2), Attempt: 2},
+		{Score: 1, FinishedAt: day(3), Attempt: 3},
+		{Score: 3, FinishedAt: day(1), Attempt: 1},
+	}
+	tests := []struct {
+		counting    AttemptCounting
+		wantScore   float64
+		wantAttempt int
+	}{
+		{counting: CountBest, wantScore: 3, wantAttempt: 1},
+		{counting: "", wantScore: 3, wantAttempt: 1},
+		{counting: CountLatest, wantScore: 1, wantAttempt: 3},
+		{counting: CountFirst, wantScore: 3, wantAttempt: 1},
+		// Средний балл всех попыток в строке последней попытки
+		{counting: CountAverage, wantScore: 2, wantAttempt: 3},
+	}
+	for _, tt := range tests {
+		got := countedResult(tt.counting, results)
+		if got.Score != tt.wantScore || got.Attempt != tt.wantAttempt {
+			t.Errorf("countedResult(%q) = балл %v, попытка %d; ожидалось %v, %d", tt.counting, got.Score, got.Attempt, tt.wantScore, tt.wantAttempt)
+		}
+	}
+}
+
+func TestAggregateLeaderboard(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 12, 0, 0, 0, time.Local) }
+	results := []TestResult{
+		{TestName: "Тест", UserID: 200, Username: "student", Score: 4, Passed: true, FinishedAt: day(1)},
+		{TestName: "Тест", UserID: 200, Username: "student", Score: 1, FinishedAt: day(2)},
+		{TestName: "Тест 2", UserID: 200, Username: "student", Score: 2, Passed: true, FinishedAt: day(1)},
+		// Баллы без зачета идут в общий балл, но не в число сданных тестов
+		{TestName: "Тест", UserID: 300, Username: "other", Score: 2, FinishedAt: day(1)},
+		{TestName: "Тест 2", UserID: 300, Username: "other", Score: 1, FinishedAt: day(1)},
+		{TestName: "Тест", UserID: 400, Username: "third", Score: 3, Passed: true, FinishedAt: day(1)},
+	}
+	tests := []struct {
+		name     string
+		counting map[string]AttemptCounting
+		want     []UserStats
+	}{
+		{
+			name: "лучшая попытка",
+			want: []UserStats{
+				{UserID: "200", Username: "student", TotalScore: 6, TotalPassed: 2},
+				// При равном балле выше тот, кто сдал больше тестов
+				{UserID: "400", Username: "third", TotalScore: 3, TotalPassed: 1},
+				{UserID: "300", Username: "other", TotalScore: 3, TotalPassed: 0},
+			},
+		},
+		{
+			// Последняя попытка в «Тест» не сдана: зачет за первую попытку не засчитывается
+			name:     "последняя попытка",
+			counting: map[string]AttemptCounting{"Тест": CountLatest},
+			want: []UserStats{
+				// При равном балле и числе сданных тестов — по имени
+				{UserID: "200", Username: "student", TotalScore: 3, TotalPassed: 1},
+				{UserID: "400", Username: "third", TotalScore: 3, TotalPassed: 1},
+				{UserID: "300", Username: "other", TotalScore: 3, TotalPassed: 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := aggregateLeaderboard(results, tt.counting)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("aggregateLeaderboard = %+v\nожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"tg_bot_module/router"
+)
+
+// errAttemptExpired — пользователь слишком долго не возвращался к начатому тесту
+var errAttemptExpired = errors.New("попытка истекла")
+
+// updateAttempt атомарно применяет fn к попытке пользователя и отмечает его активность.
+// Истекшая попытка записывается в историю как прерванная и удаляется, а вызывающий получает
+// ErrSessionNotFound, как если бы ее не было.
+func (a *app) updateAttempt(ctx context.Context, key SessionKey, fn func(*Session) error) (*Session, error) {
+	var expired *Session
+	session, err := a.sessions.Update(key, func(s *Session) error {
+		now := time.Now()
+		if s.Expired(now, a.attemptTTL) {
+			expired = s
+			return errAttemptExpired
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+		s.LastActivity = now
+		return nil
+	})
+	if errors.Is(err, errAttemptExpired) {
+		a.interruptAttempt(ctx, expired, expired.lastActive())
+		return nil, ErrSessionNotFound
+	}
+	return session, err
+}
+
+// activeSession возвращает незавершенную попытку пользователя; истекшая попытка записывается
+// в историю как прерванная и удаляется
+func (a *app) activeSession(ctx context.Context, key SessionKey) (*Session, bool) {
+	session, ok := a.sessions.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if session.Expired(time.Now(), a.attemptTTL) {
+		a.interruptAttempt(ctx, session, session.lastActive())
+		return nil, false
+	}
+	return session, true
+}
+
+// interruptAttempt записывает незавершенную попытку в историю как прерванную (с ответами,
+// данными до момента at) и удаляет ее. Прерванная попытка учитывается в лимите попыток
+// и перерыве между ними: иначе неудачную попытку можно было бы прервать и начать заново.
+func (a *app) interruptAttempt(ctx context.Context, session *Session, at time.Time) {
+	key := session.Key()
+	a.cancelTimeout(key)
+
+	if tenant, ok := a.tenants.Get(session.TenantID); ok {
+		if err := tenant.Repo.SaveAttempt(ctx, interruptedAttempt(session, at)); err != nil {
+			log.Printf("Не удалось записать прерванную попытку %s: %v", key, err)
+		}
+	} else {
+		log.Printf("Класс %q прерванной попытки %s не найден, попытка не записана", session.TenantID, key)
+	}
+
+	if err := a.sessions.Delete(key); err != nil {
+		log.Printf("Не удалось удалить попытку %s: %v", key, err)
+	}
+}
+
+// mainMenu возвращает главное меню пользователя: с кнопками "Продолжить" и "Прервать",
+// если у него есть незавершенный тест
+func (a *app) mainMenu(c *router.Context) tgbotapi.InlineKeyboardMarkup {
+	session, _ := a.activeSession(c, SessionKey{ChatID: c.ChatID(), UserID: c.From().ID})
+	return mainMenuKeyboard(session)
+}
+
+// removeQuestionKeyboard убирает кнопки у старого сообщения с вопросом
+func (a *app) removeQuestionKeyboard(chatID int64, messageID int) {
+	if messageID == 0 {
+		return
+	}
+	noKeyboard := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	a.bot.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, noKeyboard))
+}
+
+// handleResumeTest заново задает текущий вопрос незавершенного теста (кнопка "Продолжить тест").
+// Если время на вопрос вышло, пока пользователя не было, вопрос засчитывается как неотвеченный.
+func (a *app) handleResumeTest(c *router.Context) error {
+	key := SessionKey{ChatID: c.ChatID(), UserID: c.From().ID}
+
+	var qIndex, oldMessageID int
+	var late lateAnswer
+	session, err := a.updateAttempt(c, key, func(s *Session) error {
+		qIndex = s.Position
+		oldMessageID = s.QuestionMessageID
+		if s.AttemptID == "" {
+			// Попытка начата до появления идентификаторов попыток в кнопках
+			s.AttemptID = newAttemptID()
+		}
+		late.check(s, time.Now())
+		return nil
+	})
+	if errors.Is(err, ErrSessionNotFound) {
+		c.Alert("Незавершенного теста нет: возможно, попытка истекла. Выберите тест заново.")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось обновить сессию пользователя [%s]: %w", c.Username(), err)
+	}
+	log.Printf("Пользователь [%s] продолжил тест %s с вопроса %d", c.Username(), session.TestName, qIndex+1)
+
+	if late.timedOut {
+		return a.questionTimedOut(c, session, qIndex, late.messageID, late.testTimeUp)
+	}
+	a.removeQuestionKeyboard(c.ChatID(), oldMessageID)
+	return a.sendQuestion(c, session)
+}
+
+// handleAbandonTest прерывает незавершенную попытку (кнопка "Прервать тест"): она попадает
+// в историю как прерванная и расходует попытку, но в результаты теста не идет
+func (a *app) handleAbandonTest(c *router.Context) error {
+	chatID := c.ChatID()
+	key := SessionKey{ChatID: chatID, UserID: c.From().ID}
+
+	text := "Незавершенного теста нет. Выберите действие:"
+	if session, ok := a.activeSession(c, key); ok {
+		a.interruptAttempt(c, session, time.Now())
+		a.removeQuestionKeyboard(chatID, session.QuestionMessageID)
+		log.Printf("Пользователь [%s] прервал тест %s", c.Username(), session.TestName)
+		text = fmt.Sprintf("Тест «%s» прерван. Попытка засчитана как прерванная, в результаты теста она не попадет. Выберите действие:", session.TestName)
+	}
+
+	keyboard := mainMenuKeyboard(nil)
+	editMsg := tgbotapi.NewEditMessageText(chatID, c.Callback().Message.MessageID, text)
+	editMsg.ReplyMarkup = &keyboard
+	if _, err := a.bot.Send(editMsg); err != nil {
+		newMsg := tgbotapi.NewMessage(chatID, text)
+		newMsg.ReplyMarkup = keyboard
+		_, err = a.bot.Send(newMsg)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxMessageLength — ограничение Telegram на длину текста сообщения
+const maxMessageLength = 4096
+
+// answerVerdict возвращает оценку ответа: верно, частично верно, неверно или нет ответа.
+// Частичный балл и штраф за неверный ответ указываются в скобках.
+func answerVerdict(record AnswerRecord) string {
+	switch {
+	case record.TimedOut:
+		return "⏰ Нет ответа"
+	case record.Credit >= 1:
+		return "✅ Верно!"
+	case record.Credit > 0:
+		return fmt.Sprintf("🟡 Частично верно (%s балла)", formatPoints(record.Points))
+	case record.Points < 0:
+		return fmt.Sprintf("❌ Неверно (%s балла)", formatPoints(record.Points))
+	default:
+		return "❌ Неверно"
+	}
+}
+
+// givenAnswerText возвращает ответ пользователя в виде текста
+func givenAnswerText(question TestQuestion, record AnswerRecord) string {
+	if record.TimedOut {
+		return "нет ответа"
+	}
+	if question.Type == QuestionText {
+		return record.Text
+	}
+	return optionsText(question, record.Selected)
+}
+
+// correctAnswerText возвращает правильный ответ в виде текста
+func correctAnswerText(question TestQuestion) string {
+	if question.Type == QuestionText {
+		// Вопрос без допустимых ответов не проходит проверку при загрузке, но сессия могла быть
+		// начата до этой проверки
+		if len(question.AcceptedAnswers) == 0 {
+			return "не указан"
+		}
+		return question.AcceptedAnswers[0]
+	}
+	return optionsText(question, question.CorrectAnswers)
+}
+
+// optionsText перечисляет варианты с номерами numbers через запятую
+func optionsText(question TestQuestion, numbers []int) string {
+	var texts []string
+	for _, n := range numbers {
+		if n >= 1 && n <= len(question.Options) {
+			texts = append(texts, question.Options[n-1])
+		}
+	}
+	return strings.Join(texts, ", ")
+}
+
+// answerFeedback — оценка ответа, правильный ответ (если ответ неверный) и пояснение
+func answerFeedback(question TestQuestion, record AnswerRecord) string {
+	lines := []string{answerVerdict(record)}
+	if record.Credit < 1 {
+		lines = append(lines, "Правильный ответ: "+correctAnswerText(question))
+	}
+	if question.Explanation != "" {
+		lines = append(lines, "💡 "+question.Explanation)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reviewMessages собирает разбор всех вопросов попытки: вопрос, ответ пользователя рядом
+// с правильным и пояснение. Разбор делится на несколько сообщений, если не помещается в одно.
+func reviewMessages(session *Session) []string {
+	var blocks []string
+	for i, question := range session.Questions {
+		record := AnswerRecord{QuestionID: question.ID, TimedOut: true}
+		if i < len(session.Answers) {
+			record = session.Answers[i]
+		}
+
+		block := fmt.Sprintf("%d. %s\nВаш ответ: %s — %s\nПравильный ответ: %s",
+			i+1, question.Question, givenAnswerText(question, record), answerVerdict(record), correctAnswerText(question))
+		if question.Explanation != "" {
+			block += "\n💡 " + question.Explanation
+		}
+		blocks = append(blocks, block)
+	}
+	return packMessages("📋 Разбор ответов:", blocks)
+}
+
+// packMessages раскладывает заголовок и блоки текста (через пустую строку) по сообщениям не длиннее
+// maxMessageLength. Блок переносится в следующее сообщение целиком, если не помещается в текущее,
+// а блок, который не поместится ни в одно сообщение, делится на части (см. splitText).
+func packMessages(header string, blocks []string) []string {
+	var messages []string
+	current := ""
+	add := func(block string) {
+		switch {
+		case current == "":
+			current = block
+		case len(current)+len("\n\n")+len(block) <= maxMessageLength:
+			current += "\n\n" + block
+		default:
+			messages = append(messages, current)
+			current = block
+		}
+	}
+
+	for _, block := range append([]string{header}, blocks...) {
+		parts := splitText(block, maxMessageLength)
+		add(parts[0])
+		for _, part := range parts[1:] {
+			messages = append(messages, current)
+			current = part
+		}
+	}
+	return append(messages, current)
+}
+
+// splitText делит текст на части не длиннее limit байт: по последнему переводу строки,
+// а если его нет — по границе символа
+func splitText(text string, limit int) []string {
+	var parts []string
+	for len(text) > limit {
+		cut := strings.LastIndex(text[:limit], "\n")
+		if cut <= 0 {
+			cut = limit
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+		}
+		parts = append(parts, text[:cut])
+		text = strings.TrimPrefix(text[cut:], "\n")
+	}
+	return append(parts, text)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCorrectAnswerTextWithoutAcceptedAnswers(t *testing.T) {
+	question := TestQuestion{ID: "1", Question: "Столица Франции?", Type: QuestionText}
+	if got := correctAnswerText(question); got != "не указан" {
+		t.Errorf("correctAnswerText = %q", got)
+	}
+	question.AcceptedAnswers = []string{"Париж", "Paris"}
+	if got := correctAnswerText(question); got != "Париж" {
+		t.Errorf("correctAnswerText = %q, ожидался первый допустимый ответ", got)
+	}
+}
+
+// checkMessages проверяет, что все сообщения помещаются в лимит Telegram и не режут символы
+func checkMessages(t *testing.T, messages []string) {
+	t.Helper()
+	for i, message := range messages {
+		if len(message) > maxMessageLength {
+			t.Errorf("сообщение %d длиной %d больше лимита", i+1, len(message))
+		}
+		if message == "" || !utf8.ValidString(message) {
+			t.Errorf("сообщение %d пустое или разрезано посреди символа", i+1)
+		}
+	}
+}
+
+func TestReviewMessagesSplitsLongReview(t *testing.T) {
+	long := strings.Repeat("очень длинный вопрос ", 400) // больше maxMessageLength
+	session := &Session{
+		Questions: []TestQuestion{
+			{ID: "1", Question: "2+2?", Options: []string{"3", "4"}, CorrectAnswers: []int{2}},
+			{ID: "2", Question: long, Type: QuestionText, Explanation: "Пояснение"},
+			{ID: "3", Question: "Последний вопрос", Type: QuestionText, AcceptedAnswers: []string{"да"}},
+		},
+		Answers: []AnswerRecord{
+			{QuestionID: "1", Selected: []int{2}, Credit: 1, Points: 1},
+			{QuestionID: "2", Text: "не знаю"},
+		},
+	}
+
+	messages := reviewMessages(session)
+	checkMessages(t, messages)
+	if len(messages) < 3 {
+		t.Fatalf("длинный разбор уместился в %d сообщения", len(messages))
+	}
+	text := strings.Join(messages, "\n")
+	for _, want := range []string{"📋 Разбор ответов:", "1. 2+2?", "Правильный ответ: не указан", "💡 Пояснение", "3. Последний вопрос"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("в разборе нет %q", want)
+		}
+	}
+	if got := strings.Count(strings.ReplaceAll(text, "\n", ""), "очень длинный вопрос"); got != 400 {
+		t.Errorf("от длинного вопроса осталось %d повторов из 400", got)
+	}
+}
+
+func TestReviewMessagesShortReview(t *testing.T) {
+	session := &Session{
+		Questions: []TestQuestion{{ID: "1", Question: "2+2?", Options: []string{"3", "4"}, CorrectAnswers: []int{2}}},
+		Answers:   []AnswerRecord{{QuestionID: "1", Selected: []int{1}}},
+	}
+	messages := reviewMessages(session)
+	want := "📋 Разбор ответов:\n\n1. 2+2?\nВаш ответ: 3 — ❌ Неверно\nПравильный ответ: 4"
+	if len(messages) != 1 || messages[0] != want {
+		t.Errorf("reviewMessages = %q, ожидалось %q", messages, want)
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	for _, tt := range []struct {
+		text  string
+		limit int
+		want  []string
+	}{
+		{"коротко", 100, []string{"коротко"}},
+		{"строка1\nстрока2", 16, []string{"строка1", "строка2"}},
+		{"абвгд", 5, []string{"аб", "вг", "д"}},
+	} {
+		got := splitText(tt.text, tt.limit)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("splitText(%q, %d) = %q, ожидалось %q", tt.text, tt.limit, got, tt.want)
+		}
+	}
+}
+func TestWrongAnswersMessagesSplitsLongAttempt(t *testing.T) {
+	var answers []AttemptAnswer
+	for range 60 {
+		answers = append(answers, AttemptAnswer{QuestionID: "1", Question: strings.Repeat("вопрос ", 20), Answer: "ответ", MaxPoints: 1})
+	}
+	attempts := []Attempt{
+		{ID: "a1", TestName: "Тест", Score: 0, MaxScore: 60, Answers: answers},
+		{ID: "a2", TestName: "Тест", Score: 1, MaxScore: 1, Answers: []AttemptAnswer{{QuestionID: "1", Points: 1, MaxPoints: 1}}},
+	}
+
+	messages := wrongAnswersMessages("@student", attempts)
+	checkMessages(t, messages)
+	if len(messages) < 2 {
+		t.Fatalf("длинная история уместилась в %d сообщение", len(messages))
+	}
+	text := strings.Join(messages, "\n")
+	if got := strings.Count(text, "❌ 1."); got != 60 {
+		t.Errorf("в истории %d ошибок из 60", got)
+	}
+	if !strings.HasPrefix(messages[0], "📋 Попытки ученика @student:") {
+		t.Errorf("первое сообщение = %q", messages[0])
+	}
+}
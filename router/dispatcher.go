@@ -0,0 +1,106 @@
+package router
+
+import (
+	"context"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Job — единица работы, выполняемая воркером диспетчера
+type Job func(ctx context.Context)
+
+// Dispatcher выполняет задачи в нескольких воркерах параллельно, сохраняя порядок задач одного чата:
+// все задачи чата попадают в очередь одного и того же воркера.
+// Очереди ограничены, поэтому при перегрузке Submit блокируется, а не копит обновления без предела.
+type Dispatcher struct {
+	queues []chan Job
+	wg     sync.WaitGroup
+
+	mu      sync.RWMutex
+	stopped bool
+}
+
+// NewDispatcher создает диспетчер с workers воркерами и очередью на queueSize задач у каждого
+func NewDispatcher(workers, queueSize int) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	d := &Dispatcher{queues: make([]chan Job, workers)}
+	for i := range d.queues {
+		d.queues[i] = make(chan Job, queueSize)
+	}
+	return d
+}
+
+// Start запускает воркеры. ctx передается в каждую задачу.
+func (d *Dispatcher) Start(ctx context.Context) {
+	for _, queue := range d.queues {
+		d.wg.Add(1)
+		go func(queue chan Job) {
+			defer d.wg.Done()
+			for job := range queue {
+				job(ctx)
+			}
+		}(queue)
+	}
+}
+
+// Submit ставит задачу в очередь воркера, отвечающего за чат chatID.
+// Если очередь заполнена, вызов ждет освобождения места. После Stop возвращает false.
+func (d *Dispatcher) Submit(chatID int64, job Job) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.stopped {
+		return false
+	}
+	d.queues[uint64(chatID)%uint64(len(d.queues))] <- job
+	return true
+}
+
+// Serve читает обновления из канала и передает их в роутер, пока канал не будет закрыт
+// или не будет отменен ctx. Уже принятые обновления продолжают выполняться до вызова Stop.
+func (d *Dispatcher) Serve(ctx context.Context, updates <-chan tgbotapi.Update, r *Router) {
+	for {
+		var update tgbotapi.Update
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok = <-updates:
+			if !ok {
+				return
+			}
+		}
+
+		var chatID int64
+		if chat := update.FromChat(); chat != nil {
+			chatID = chat.ID
+		} else if user := update.SentFrom(); user != nil {
+			chatID = user.ID
+		}
+
+		d.Submit(chatID, func(ctx context.Context) {
+			r.Handle(ctx, update)
+		})
+	}
+}
+
+// Stop перестает принимать задачи и ждет, пока воркеры выполнят уже поставленные в очередь
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	if !d.stopped {
+		d.stopped = true
+		for _, queue := range d.queues {
+			close(queue)
+		}
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
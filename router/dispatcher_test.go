@@ -0,0 +1,122 @@
+package router
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatcherKeepsChatOrder(t *testing.T) {
+	const jobs = 200
+	chats := []int64{1, 2, 3, 4, -100500, 1 << 40}
+
+	d := NewDispatcher(3, 4)
+	d.Start(context.Background())
+
+	var mu sync.Mutex
+	done := make(map[int64][]int)
+
+	// Чаты ставят задачи одновременно, поэтому задачи разных чатов в очередях перемешиваются
+	var wg sync.WaitGroup
+	for _, chatID := range chats {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < jobs; i++ {
+				ok := d.Submit(chatID, func(ctx context.Context) {
+					if i%7 == 0 {
+						runtime.Gosched()
+					}
+					mu.Lock()
+					done[chatID] = append(done[chatID], i)
+					mu.Unlock()
+				})
+				if !ok {
+					t.Errorf("чат %d: задача %d не принята", chatID, i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	d.Stop()
+
+	for _, chatID := range chats {
+		got := done[chatID]
+		if len(got) != jobs {
+			t.Errorf("чат %d: выполнено задач %d, ожидалось %d", chatID, len(got), jobs)
+			continue
+		}
+		if !slices.IsSorted(got) {
+			t.Errorf("чат %d: задачи выполнены не по порядку: %v", chatID, got)
+		}
+	}
+}
+
+func TestDispatcherStopDrainsQueue(t *testing.T) {
+	const queued = 10
+	d := NewDispatcher(1, queued)
+	d.Start(context.Background())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	d.Submit(1, func(ctx context.Context) {
+		close(started)
+		<-release
+	})
+	<-started
+
+	var mu sync.Mutex
+	var done []int
+	for i := 0; i < queued; i++ {
+		d.Submit(int64(i), func(ctx context.Context) {
+			mu.Lock()
+			done = append(done, i)
+			mu.Unlock()
+		})
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		d.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("Stop вернулся, пока задача еще выполняется")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop не дождался очереди")
+	}
+	if len(done) != queued {
+		t.Errorf("после Stop выполнено задач %d из %d", len(done), queued)
+	}
+	if d.Submit(1, func(ctx context.Context) {}) {
+		t.Error("после Stop задача принята")
+	}
+}
+
+func TestDispatcherPassesContext(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "значение")
+
+	d := NewDispatcher(2, 0)
+	d.Start(ctx)
+	var got any
+	d.Submit(5, func(ctx context.Context) {
+		got = ctx.Value(key{})
+	})
+	d.Stop()
+
+	if got != "значение" {
+		t.Errorf("задача получила контекст без значения: %v", got)
+	}
+}
@@ -0,0 +1,94 @@
+package router
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Logger логирует каждое обновление, время его обработки и ошибку обработчика
+func Logger() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			start := time.Now()
+			if callback := c.Callback(); callback != nil {
+				log.Printf("Получен Callback от [%s]: %s", callback.From.UserName, callback.Data)
+			} else if msg := c.Update.Message; msg != nil && msg.From != nil {
+				log.Printf("[%s] %s", msg.From.UserName, msg.Text)
+			}
+
+			err := next(c)
+			if err != nil {
+				log.Printf("Ошибка обработки обновления %d от [%s] (%s): %v", c.Update.UpdateID, c.Username(), time.Since(start), err)
+			}
+			return err
+		}
+	}
+}
+
+// Recoverer перехватывает панику обработчика и превращает ее в ошибку,
+// чтобы падение на одном обновлении не останавливало бота
+func Recoverer() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					log.Printf("Паника при обработке обновления %d: %v\n%s", c.Update.UpdateID, p, debug.Stack())
+					err = fmt.Errorf("паника в обработчике: %v", p)
+				}
+			}()
+			return next(c)
+		}
+	}
+}
+
+// AnswerCallback отвечает на нажатие кнопки после обработчика. Текст ответа обработчик задает
+// через Context.Answer или Context.Alert; если он не задан, используется defaultText.
+func AnswerCallback(defaultText string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			err := next(c)
+
+			callback := c.Callback()
+			if callback == nil {
+				return err
+			}
+
+			text := defaultText
+			if c.answerSet {
+				text = c.answerText
+			}
+			config := tgbotapi.NewCallback(callback.ID, text)
+			config.ShowAlert = c.answerAlert
+			if _, reqErr := c.Bot.Request(config); reqErr != nil {
+				log.Printf("Не удалось ответить на Callback %s: %v", callback.ID, reqErr)
+			}
+			return err
+		}
+	}
+}
+
+// Auth пропускает к обработчику только пользователей, для которых allow возвращает true.
+// Остальным отправляется deniedText (во всплывающем окне или сообщением).
+func Auth(allow func(user *tgbotapi.User) bool, deniedText string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			user := c.From()
+			if user != nil && allow(user) {
+				return next(c)
+			}
+
+			if c.Callback() != nil {
+				c.Alert(deniedText)
+				return nil
+			}
+			if _, err := c.Bot.Send(tgbotapi.NewMessage(c.ChatID(), deniedText)); err != nil {
+				return err
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package router распределяет обновления Telegram по обработчикам команд, callback-кнопок и текста.
+package router
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Context передается обработчику и содержит обрабатываемое обновление.
+// Встроенный context.Context отменяется при остановке бота, его можно передавать во все внешние вызовы.
+type Context struct {
+	context.Context
+	Bot    *tgbotapi.BotAPI
+	Update tgbotapi.Update
+
+	answerText  string
+	answerAlert bool
+	answerSet   bool
+}
+
+// Callback возвращает нажатие inline-кнопки или nil, если обновление — сообщение
+func (c *Context) Callback() *tgbotapi.CallbackQuery {
+	return c.Update.CallbackQuery
+}
+
+// Message возвращает сообщение: само сообщение или сообщение, к которому привязана нажатая кнопка
+func (c *Context) Message() *tgbotapi.Message {
+	if c.Update.CallbackQuery != nil {
+		return c.Update.CallbackQuery.Message
+	}
+	return c.Update.Message
+}
+
+// From возвращает пользователя, отправившего обновление
+func (c *Context) From() *tgbotapi.User {
+	return c.Update.SentFrom()
+}
+
+// ChatID возвращает ID чата, из которого пришло обновление
+func (c *Context) ChatID() int64 {
+	if chat := c.Update.FromChat(); chat != nil {
+		return chat.ID
+	}
+	return 0
+}
+
+// Username возвращает юзернейм отправителя или "ID_<id>", если юзернейма нет
+func (c *Context) Username() string {
+	user := c.From()
+	if user == nil {
+		return ""
+	}
+	if user.UserName != "" {
+		return user.UserName
+	}
+	return fmt.Sprintf("ID_%d", user.ID)
+}
+
+// Answer задает текст всплывающего уведомления в ответ на нажатие кнопки
+func (c *Context) Answer(text string) {
+	c.answerText, c.answerAlert, c.answerSet = text, false, true
+}
+
+// Alert задает текст окна-предупреждения в ответ на нажатие кнопки
+func (c *Context) Alert(text string) {
+	c.answerText, c.answerAlert, c.answerSet = text, true, true
+}
+
+// HandlerFunc обрабатывает одно обновление. Ошибка завершает обработку только этого обновления.
+type HandlerFunc func(c *Context) error
+
+// Middleware оборачивает обработчик дополнительной логикой
+type Middleware func(next HandlerFunc) HandlerFunc
+
+type prefixRoute struct {
+	prefix  string
+	handler HandlerFunc
+}
+
+// Router хранит зарегистрированные обработчики и общие middleware
+type Router struct {
+	bot            *tgbotapi.BotAPI
+	middleware     []Middleware
+	commands       map[string]HandlerFunc
+	unknownCommand HandlerFunc
+	callbacks      map[string]HandlerFunc
+	prefixes       []prefixRoute
+	text           HandlerFunc
+}
+
+// New создает пустой роутер для бота
+func New(bot *tgbotapi.BotAPI) *Router {
+	return &Router{
+		bot:       bot,
+		commands:  make(map[string]HandlerFunc),
+		callbacks: make(map[string]HandlerFunc),
+	}
+}
+
+// Use добавляет middleware, которые применяются ко всем обработчикам.
+// Первый переданный middleware выполняется первым (оборачивает остальные).
+func (r *Router) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Command регистрирует обработчик команды /name
+func (r *Router) Command(name string, h HandlerFunc, mw ...Middleware) {
+	r.commands[name] = wrap(h, mw)
+}
+
+// UnknownCommand регистрирует обработчик для незарегистрированных команд
+func (r *Router) UnknownCommand(h HandlerFunc, mw ...Middleware) {
+	r.unknownCommand = wrap(h, mw)
+}
+
+// Callback регистрирует обработчик нажатия кнопки с данными data
+func (r *Router) Callback(data string, h HandlerFunc, mw ...Middleware) {
+	r.callbacks[data] = wrap(h, mw)
+}
+
+// CallbackPrefix регистрирует обработчик кнопок, данные которых начинаются с prefix.
+// Если подходит несколько префиксов, выбирается самый длинный.
+func (r *Router) CallbackPrefix(prefix string, h HandlerFunc, mw ...Middleware) {
+	r.prefixes = append(r.prefixes, prefixRoute{prefix: prefix, handler: wrap(h, mw)})
+	sort.SliceStable(r.prefixes, func(i, j int) bool {
+		return len(r.prefixes[i].prefix) > len(r.prefixes[j].prefix)
+	})
+}
+
+// Text регистрирует обработчик обычных текстовых сообщений (не команд)
+func (r *Router) Text(h HandlerFunc, mw ...Middleware) {
+	r.text = wrap(h, mw)
+}
+
+// Handle обрабатывает одно обновление. Ошибки и паники обработчика (при подключенном Recoverer)
+// возвращаются вызывающему и не влияют на обработку других обновлений.
+func (r *Router) Handle(ctx context.Context, update tgbotapi.Update) error {
+	handler := r.route(update)
+	if handler == nil {
+		return nil
+	}
+
+	c := &Context{Context: ctx, Bot: r.bot, Update: update}
+	return wrap(handler, r.middleware)(c)
+}
+
+// route находит обработчик для обновления или возвращает nil
+func (r *Router) route(update tgbotapi.Update) HandlerFunc {
+	switch {
+	case update.CallbackQuery != nil:
+		data := update.CallbackQuery.Data
+		if h, ok := r.callbacks[data]; ok {
+			return h
+		}
+		for _, route := range r.prefixes {
+			if strings.HasPrefix(data, route.prefix) {
+				return route.handler
+			}
+		}
+		// Кнопку без обработчика все равно нужно "ответить", чтобы у пользователя пропали часики
+		return func(c *Context) error { return nil }
+
+	case update.Message != nil && update.Message.IsCommand():
+		if h, ok := r.commands[update.Message.Command()]; ok {
+			return h
+		}
+		return r.unknownCommand
+
+	case update.Message != nil:
+		return r.text
+	}
+	return nil
+}
+
+// wrap оборачивает обработчик цепочкой middleware так, что mw[0] выполняется первым
+func wrap(h HandlerFunc, mw []Middleware) HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
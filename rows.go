@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Разбор строк таблицы в типизированные значения. Google Sheets отдает ячейки необработанными
+// (UNFORMATTED_VALUE): число приходит числом, текст — строкой, пустые ячейки в конце строки
+// обрезаются. Ошибка в ячейке указывает вкладку, строку и колонку так, как их видит преподаватель.
+
+// CellError — некорректное значение в ячейке таблицы
+type CellError struct {
+	Sheet string
+	// Cell — адрес ячейки, например F7
+	Cell string
+	Err  error
+}
+
+func (e *CellError) Error() string {
+	return fmt.Sprintf("%s!%s: %v", e.Sheet, e.Cell, e.Err)
+}
+
+func (e *CellError) Unwrap() error {
+	return e.Err
+}
+
+// sheetTable — строки диапазона вкладки вместе с положением диапазона в таблице
+type sheetTable struct {
+	Sheet string
+	Range a1Range
+	Rows  [][]interface{}
+}
+
+// decoder возвращает разборщик строки с индексом i
+func (t sheetTable) decoder(i int) *rowDecoder {
+	return &rowDecoder{table: t, index: i, row: t.Rows[i]}
+}
+
+// rowDecoder читает значения ячеек одной строки диапазона и накапливает ошибки по ячейкам.
+// Колонки задаются индексом внутри диапазона (с нуля); индекс меньше нуля — колонка не задана.
+type rowDecoder struct {
+	table sheetTable
+	index int
+	row   []interface{}
+	errs  []error
+}
+
+// Cell возвращает адрес ячейки колонки col в этой строке, например F7
+func (d *rowDecoder) Cell(col int) string {
+	return fmt.Sprintf("%s%d", columnLetter(columnNumber(d.table.Range.StartColumn)+col), d.table.Range.StartRow+d.index)
+}
+
+// Fail записывает ошибку в ячейке колонки col
+func (d *rowDecoder) Fail(col int, format string, args ...interface{}) {
+	d.errs = append(d.errs, &CellError{Sheet: d.table.Sheet, Cell: d.Cell(max(col, 0)), Err: fmt.Errorf(format, args...)})
+}
+
+// Errors возвращает ошибки, найденные в строке
+func (d *rowDecoder) Errors() []error {
+	return d.errs
+}
+
+// OK сообщает, что ошибок в строке не найдено
+func (d *rowDecoder) OK() bool {
+	return len(d.errs) == 0
+}
+
+// Empty сообщает, что в строке нет ни одной заполненной ячейки
+func (d *rowDecoder) Empty() bool {
+	for i := range d.row {
+		if strings.TrimSpace(cellText(d.row, i)) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Text возвращает текст ячейки; пустая, отсутствующая или незаданная колонка — пустая строка
+func (d *rowDecoder) Text(col int) string {
+	return cellText(d.row, col)
+}
+
+// RequiredText возвращает текст ячейки и записывает ошибку, если ячейка пуста
+func (d *rowDecoder) RequiredText(col int, name string) string {
+	text := d.Text(col)
+	if strings.TrimSpace(text) == "" {
+		d.Fail(col, "не заполнена колонка %q", name)
+	}
+	return text
+}
+
+// Int64 возвращает целое число из обязательной ячейки (например, Telegram ID)
+func (d *rowDecoder) Int64(col int, name string) int64 {
+	if col >= 0 && col < len(d.row) {
+		if number, ok := d.row[col].(float64); ok {
+			if number != math.Trunc(number) {
+				d.Fail(col, "колонка %q: ожидается целое число, получено %s", name, formatPoints(number))
+				return 0
+			}
+			return int64(number)
+		}
+	}
+	text := strings.TrimSpace(d.Text(col))
+	if text == "" {
+		d.Fail(col, "не заполнена колонка %q", name)
+		return 0
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		d.Fail(col, "колонка %q: ожидается целое число, получено %q", name, text)
+	}
+	return n
+}
+
+// Int возвращает целое число из необязательной ячейки; пустая ячейка — 0
+func (d *rowDecoder) Int(col int, name string) int {
+	if strings.TrimSpace(d.Text(col)) == "" {
+		return 0
+	}
+	return int(d.Int64(col, name))
+}
+
+// Float возвращает число из необязательной ячейки; пустая ячейка — 0.
+// Текст с десятичной запятой тоже разбирается.
+func (d *rowDecoder) Float(col int, name string) float64 {
+	if col >= 0 && col < len(d.row) {
+		if number, ok := d.row[col].(float64); ok {
+			return number
+		}
+	}
+	text := strings.TrimSpace(d.Text(col))
+	if text == "" {
+		return 0
+	}
+	number, err := parsePoints(text)
+	if err != nil {
+		d.Fail(col, "колонка %q: ожидается число, получено %q", name, text)
+	}
+	return number
+}
+
+// RequiredFloat возвращает число из обязательной ячейки
+func (d *rowDecoder) RequiredFloat(col int, name string) float64 {
+	if strings.TrimSpace(d.Text(col)) == "" {
+		d.Fail(col, "не заполнена колонка %q", name)
+		return 0
+	}
+	return d.Float(col, name)
+}
+
+// sheetTimeLayouts — форматы времени в ячейках: как его записывает бот и как Sheets показывает даты
+var sheetTimeLayouts = []string{"2006-01-02 15:04:05", "02.01.2006 15:04:05", "1/2/2006 15:04:05", "2006-01-02 15:04", "02.01.2006 15:04", "2006-01-02", "02.01.2006"}
+
+// Time возвращает время из необязательной ячейки; пустая ячейка — нулевое время.
+// Дата, которую Sheets хранит числом (дни с 30.12.1899), тоже разбирается.
+func (d *rowDecoder) Time(col int, name string) time.Time {
+	if col >= 0 && col < len(d.row) {
+		if days, ok := d.row[col].(float64); ok {
+			epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.Local)
+			return epoch.Add(time.Duration(days * float64(24*time.Hour))).Round(time.Second)
+		}
+	}
+	text := strings.TrimSpace(d.Text(col))
+	if text == "" {
+		return time.Time{}
+	}
+	for _, layout := range sheetTimeLayouts {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t
+		}
+	}
+	d.Fail(col, "колонка %q: ожидается время вида 2006-01-02 15:04:05, получено %q", name, text)
+	return time.Time{}
+}
+
+// Duration возвращает длительность из необязательной ячейки ("2m35s"); пустая ячейка — 0
+func (d *rowDecoder) Duration(col int, name string) time.Duration {
+	text := strings.TrimSpace(d.Text(col))
+	if text == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(text)
+	if err != nil {
+		d.Fail(col, "колонка %q: ожидается длительность вида 2m35s, получено %q", name, text)
+	}
+	return duration
+}
+
+// Flag возвращает логическое значение ячейки: да/нет, yes/no, true/false (в том числе флажок Sheets).
+// Пустая ячейка — значение empty.
+func (d *rowDecoder) Flag(col int, name string, empty bool) bool {
+	if col >= 0 && col < len(d.row) {
+		if value, ok := d.row[col].(bool); ok {
+			return value
+		}
+	}
+	text := strings.ToLower(strings.TrimSpace(d.Text(col)))
+	if text == "" {
+		return empty
+	}
+	value, ok := parseSwitch(text)
+	if !ok {
+		d.Fail(col, "колонка %q: ожидается да или нет, получено %q", name, text)
+	}
+	return value
+}
+
+// logCellErrors записывает в лог ошибки разбора таблицы
+func logCellErrors(errs []error) {
+	for _, err := range errs {
+		log.Printf("Ошибка в таблице: %v", err)
+	}
+}
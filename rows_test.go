@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRowDecoder(t *testing.T) {
+	// Диапазон начинается не с A1: адрес ячейки учитывает смещение диапазона
+	cells, err := parseA1Range("C3:H")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		row      []interface{}
+		decode   func(d *rowDecoder) interface{}
+		want     interface{}
+		wantCell string
+	}{
+		{name: "ID числом", row: []interface{}{200.0}, decode: func(d *rowDecoder) interface{} { return d.Int64(0, "UserID") }, want: int64(200)},
+		{name: "ID текстом", row: []interface{}{" 200 "}, decode: func(d *rowDecoder) interface{} { return d.Int64(0, "UserID") }, want: int64(200)},
+		{name: "ID не число", row: []interface{}{"ученик"}, decode: func(d *rowDecoder) interface{} { return d.Int64(0, "UserID") }, want: int64(0), wantCell: "C4"},
+		{name: "дробный ID", row: []interface{}{2.5}, decode: func(d *rowDecoder) interface{} { return d.Int64(0, "UserID") }, want: int64(0), wantCell: "C4"},
+		{name: "пустой ID", row: []interface{}{""}, decode: func(d *rowDecoder) interface{} { return d.Int64(0, "UserID") }, want: int64(0), wantCell: "C4"},
+		{name: "пустое необязательное число", row: []interface{}{"", ""}, decode: func(d *rowDecoder) interface{} { return d.Int(1, "Попытка") }, want: 0},
+		{name: "число с запятой", row: []interface{}{"", "2,5"}, decode: func(d *rowDecoder) interface{} { return d.Float(1, "Баллы") }, want: 2.5},
+		{name: "не число", row: []interface{}{"", "много"}, decode: func(d *rowDecoder) interface{} { return d.Float(1, "Баллы") }, want: 0.0, wantCell: "D4"},
+		{name: "обязательное число пусто", row: []interface{}{""}, decode: func(d *rowDecoder) interface{} { return d.RequiredFloat(3, "Баллы") }, want: 0.0, wantCell: "F4"},
+		{name: "обязательный текст пуст", row: []interface{}{"1", "  "}, decode: func(d *rowDecoder) interface{} { return d.RequiredText(1, "вопрос") }, want: "  ", wantCell: "D4"},
+		{name: "колонка не задана", row: []interface{}{"1"}, decode: func(d *rowDecoder) interface{} { return d.Text(-1) }, want: ""},
+		{name: "время текстом", row: []interface{}{"2026-10-01 12:00:00"}, decode: func(d *rowDecoder) interface{} { return d.Time(0, "Завершен") }, want: time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)},
+		{name: "время числом", row: []interface{}{dateSerial(2026, time.October, 1) + 0.5}, decode: func(d *rowDecoder) interface{} { return d.Time(0, "Завершен") }, want: time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)},
+		{name: "время в формате Sheets", row: []interface{}{"01.10.2026 12:00:00"}, decode: func(d *rowDecoder) interface{} { return d.Time(0, "Завершен") }, want: time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)},
+		{name: "неверное время", row: []interface{}{"вчера"}, decode: func(d *rowDecoder) interface{} { return d.Time(0, "Завершен") }, want: time.Time{}, wantCell: "C4"},
+		{name: "длительность", row: []interface{}{"2m35s"}, decode: func(d *rowDecoder) interface{} { return d.Duration(0, "Длительность") }, want: 155 * time.Second},
+		{name: "неверная длительность", row: []interface{}{"2 минуты"}, decode: func(d *rowDecoder) interface{} { return d.Duration(0, "Длительность") }, want: time.Duration(0), wantCell: "C4"},
+		{name: "флажок", row: []interface{}{false}, decode: func(d *rowDecoder) interface{} { return d.Flag(0, "Зачет", true) }, want: false},
+		{name: "да", row: []interface{}{"Да"}, decode: func(d *rowDecoder) interface{} { return d.Flag(0, "Зачет", false) }, want: true},
+		{name: "пустой флаг", row: []interface{}{}, decode: func(d *rowDecoder) interface{} { return d.Flag(0, "Зачет", true) }, want: true},
+		{name: "неверный флаг", row: []interface{}{"может быть"}, decode: func(d *rowDecoder) interface{} { return d.Flag(0, "Зачет", true) }, want: false, wantCell: "C4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Разбирается вторая строка диапазона — строка 4 таблицы
+			table := sheetTable{Sheet: "Тест", Range: cells, Rows: [][]interface{}{nil, tt.row}}
+			d := table.decoder(1)
+			got := tt.decode(d)
+			if got != tt.want {
+				t.Errorf("значение %#v, ожидалось %#v", got, tt.want)
+			}
+			if tt.wantCell == "" {
+				if !d.OK() {
+					t.Errorf("неожиданные ошибки: %v", d.Errors())
+				}
+				return
+			}
+			var cellErr *CellError
+			if len(d.Errors()) != 1 || !errors.As(d.Errors()[0], &cellErr) {
+				t.Fatalf("ошибки %v, ожидалась одна ошибка ячейки", d.Errors())
+			}
+			if cellErr.Sheet != "Тест" || cellErr.Cell != tt.wantCell {
+				t.Errorf("ошибка в %s!%s, ожидалась в Тест!%s", cellErr.Sheet, cellErr.Cell, tt.wantCell)
+			}
+		})
+	}
+}
+
+func TestCellError(t *testing.T) {
+	cause := errors.New("ожидается число")
+	err := error(&CellError{Sheet: "Тест 2", Cell: "F7", Err: cause})
+	if got := err.Error(); got != "Тест 2!F7: ожидается число" {
+		t.Errorf("Error() = %q", got)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("причина ошибки не доступна через errors.Is")
+	}
+}
+
+func TestRowDecoderEmpty(t *testing.T) {
+	tests := []struct {
+		row  []interface{}
+		want bool
+	}{
+		{row: nil, want: true},
+		{row: []interface{}{"", " ", nil}, want: true},
+		{row: []interface{}{"", 0.0}, want: false},
+		{row: []interface{}{"", "а"}, want: false},
+	}
+	for _, tt := range tests {
+		d := sheetTable{Sheet: "Тест", Rows: [][]interface{}{tt.row}}.decoder(0)
+		if got := d.Empty(); got != tt.want {
+			t.Errorf("Empty(%q) = %t, ожидалось %t", tt.row, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// SessionKey идентифицирует попытку прохождения теста: один пользователь в одном чате
+type SessionKey struct {
+	ChatID int64
+	UserID int64
+}
+
+func (k SessionKey) String() string {
+	return fmt.Sprintf("%d:%d", k.ChatID, k.UserID)
+}
+
+// Session хранит состояние прохождения теста конкретным пользователем
+type Session struct {
+	ChatID   int64
+	UserID   int64
+	Username string
+	TenantID string
+	// AttemptID отличает кнопки этой попытки от кнопок прошлых попыток того же пользователя
+	AttemptID string
+	TestName  string
+	Questions []TestQuestion
+	// OptionOrder — порядок показа вариантов каждого вопроса (номера исходных вариантов);
+	// nil, если варианты не перемешиваются
+	OptionOrder [][]int
+	Settings    TestSettings
+	Position    int
+	// Score — набранные баллы с учетом весов вопросов и штрафов
+	Score float64
+	// Selected — варианты, отмеченные в текущем вопросе с несколькими ответами
+	Selected []int
+
+	// StartedAt — начало прохождения; Deadline — когда истекает время на весь тест
+	StartedAt time.Time
+	Deadline  time.Time
+	// QuestionMessageID — сообщение с текущим вопросом; QuestionDeadline — когда истекает время на него
+	QuestionMessageID int
+	QuestionDeadline  time.Time
+	// LastActivity — когда пользователь последний раз отвечал или получал вопрос
+	LastActivity time.Time
+
+	// Answers — ответы на уже пройденные вопросы, по порядку вопросов
+	Answers []AnswerRecord
+}
+
+// AnswerRecord — ответ пользователя на один вопрос
+type AnswerRecord struct {
+	QuestionID string
+	// Selected — номера выбранных исходных вариантов; Text — свободный ответ
+	Selected []int
+	Text     string
+	// Credit — доля засчитанного ответа от 0 до 1; Points — начисленные баллы с учетом веса
+	// вопроса и штрафа за неверный ответ (могут быть отрицательными)
+	Credit float64
+	Points float64
+	// TimedOut — пользователь не успел ответить
+	TimedOut bool
+}
+
+// newAttemptID возвращает случайный короткий идентификатор попытки для данных кнопок
+func newAttemptID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Key возвращает ключ, под которым сессия хранится
+func (s *Session) Key() SessionKey {
+	return SessionKey{ChatID: s.ChatID, UserID: s.UserID}
+}
+
+// Finished сообщает, что все вопросы теста уже заданы
+func (s *Session) Finished() bool {
+	return s.Position >= len(s.Questions)
+}
+
+// CurrentQuestion возвращает вопрос, на котором сейчас находится пользователь
+func (s *Session) CurrentQuestion() (TestQuestion, bool) {
+	if s.Finished() {
+		return TestQuestion{}, false
+	}
+	return s.Questions[s.Position], true
+}
+
+// OptionOrderFor возвращает номера исходных вариантов вопроса qIndex в порядке показа
+func (s *Session) OptionOrderFor(qIndex int) []int {
+	if qIndex < len(s.OptionOrder) && s.OptionOrder[qIndex] != nil {
+		return s.OptionOrder[qIndex]
+	}
+	order := make([]int, len(s.Questions[qIndex].Options))
+	for i := range order {
+		order[i] = i + 1
+	}
+	return order
+}
+
+// OriginalOption переводит номер кнопки (позицию варианта на экране, с единицы) в номер
+// исходного варианта вопроса qIndex
+func (s *Session) OriginalOption(qIndex, displayed int) (int, bool) {
+	order := s.OptionOrderFor(qIndex)
+	if displayed < 1 || displayed > len(order) {
+		return 0, false
+	}
+	return order[displayed-1], true
+}
+
+// ToggleSelected отмечает вариант option текущего вопроса или снимает отметку
+func (s *Session) ToggleSelected(option int) {
+	for i, selected := range s.Selected {
+		if selected == option {
+			s.Selected = append(s.Selected[:i], s.Selected[i+1:]...)
+			return
+		}
+	}
+	s.Selected = append(s.Selected, option)
+}
+
+// IsSelected сообщает, отмечен ли вариант option в текущем вопросе
+func (s *Session) IsSelected(option int) bool {
+	for _, selected := range s.Selected {
+		if selected == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Answer засчитывает ответ selected на текущий вопрос и переходит к следующему.
+// Возвращает долю засчитанного ответа.
+func (s *Session) Answer(selected []int) float64 {
+	question, ok := s.CurrentQuestion()
+	if !ok {
+		return 0
+	}
+	selected = append([]int(nil), selected...)
+	sort.Ints(selected)
+	return s.advance(AnswerRecord{
+		QuestionID: question.ID,
+		Selected:   selected,
+		Credit:     question.Score(selected, s.Settings.Scoring),
+	})
+}
+
+// advance записывает ответ на текущий вопрос, начисляет баллы и переходит к следующему.
+// Возвращает долю засчитанного ответа.
+func (s *Session) advance(record AnswerRecord) float64 {
+	if !record.TimedOut {
+		record.Points = s.points(record.Credit)
+	}
+	s.Answers = append(s.Answers, record)
+	s.Score += record.Points
+	s.Position++
+	s.Selected = nil
+	s.QuestionDeadline = time.Time{}
+	return record.Credit
+}
+
+// points переводит долю засчитанного ответа на текущий вопрос в баллы: доля веса вопроса,
+// а за полностью неверный ответ — штраф wrong_penalty
+func (s *Session) points(credit float64) float64 {
+	question, _ := s.CurrentQuestion()
+	if credit > 0 {
+		return credit * question.Weight()
+	}
+	return -s.Settings.WrongPenalty * question.Weight()
+}
+
+// MaxScore возвращает, сколько баллов можно набрать за все вопросы попытки
+func (s *Session) MaxScore() float64 {
+	total := 0.0
+	for _, question := range s.Questions {
+		total += question.Weight()
+	}
+	return total
+}
+
+// FinalScore возвращает итоговый балл попытки: штрафы не опускают его ниже нуля
+func (s *Session) FinalScore() float64 {
+	return max(0, s.Score)
+}
+
+// AnswerText засчитывает свободный ответ text на текущий вопрос и переходит к следующему.
+// Возвращает долю засчитанного ответа.
+func (s *Session) AnswerText(text string) float64 {
+	question, ok := s.CurrentQuestion()
+	if !ok {
+		return 0
+	}
+	record := AnswerRecord{QuestionID: question.ID, Text: text}
+	if question.CheckText(text, s.Settings) {
+		record.Credit = 1
+	}
+	return s.advance(record)
+}
+
+// QuestionTimeUp сообщает, что время на текущий вопрос (или на весь тест) истекло
+func (s *Session) QuestionTimeUp(now time.Time) bool {
+	return (!s.QuestionDeadline.IsZero() && !now.Before(s.QuestionDeadline)) || s.TestTimeUp(now)
+}
+
+// TestTimeUp сообщает, что время на весь тест истекло
+func (s *Session) TestTimeUp(now time.Time) bool {
+	return !s.Deadline.IsZero() && !now.Before(s.Deadline)
+}
+
+// TimeOut пропускает текущий вопрос без баллов, а если истекло время на весь тест —
+// все оставшиеся вопросы. Возвращает true во втором случае.
+func (s *Session) TimeOut(now time.Time) bool {
+	testTimeUp := s.TestTimeUp(now)
+	for !s.Finished() {
+		question, _ := s.CurrentQuestion()
+		s.advance(AnswerRecord{QuestionID: question.ID, TimedOut: true})
+		if !testTimeUp {
+			break
+		}
+	}
+	return testTimeUp
+}
+
+// NextQuestionDeadline возвращает, когда истечет время на вопрос, заданный в момент now:
+// ограничение на вопрос, но не позже окончания времени на тест. Нулевое время — без ограничения.
+func (s *Session) NextQuestionDeadline(now time.Time) time.Time {
+	var deadline time.Time
+	if limit := s.Settings.QuestionTimeLimit; limit > 0 {
+		deadline = now.Add(limit)
+	}
+	if !s.Deadline.IsZero() && (deadline.IsZero() || s.Deadline.Before(deadline)) {
+		deadline = s.Deadline
+	}
+	return deadline
+}
+
+// Expired сообщает, что попытка брошена: пользователь не проявлял активности дольше ttl.
+// При ttl == 0 попытки не истекают.
+func (s *Session) Expired(now time.Time, ttl time.Duration) bool {
+	last := s.lastActive()
+	return ttl > 0 && !last.IsZero() && now.Sub(last) > ttl
+}
+
+// lastActive возвращает время последней активности пользователя в попытке
+func (s *Session) lastActive() time.Time {
+	if s.LastActivity.IsZero() {
+		return s.StartedAt
+	}
+	return s.LastActivity
+}
+
+// Elapsed возвращает время, прошедшее с начала теста
+func (s *Session) Elapsed(now time.Time) time.Duration {
+	if s.StartedAt.IsZero() {
+		return 0
+	}
+	return now.Sub(s.StartedAt)
+}
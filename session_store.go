@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrSessionNotFound возвращается, когда у пользователя нет активной сессии
+var ErrSessionNotFound = errors.New("сессия не найдена")
+
+// SessionStore хранит активные сессии прохождения тестов.
+// Реализации должны быть безопасны для одновременного использования из нескольких горутин.
+// Get и Update отдают копию сессии, поэтому изменения вне Update/Put в хранилище не попадают.
+type SessionStore interface {
+	Get(key SessionKey) (*Session, bool)
+	Put(session *Session) error
+	// Update атомарно применяет fn к сохраненной сессии и возвращает ее новое состояние
+	Update(key SessionKey, fn func(*Session) error) (*Session, error)
+	Delete(key SessionKey) error
+}
+
+// clone возвращает копию сессии, которую можно менять без блокировок.
+// Вопросы теста и порядок вариантов после начала попытки не меняются, поэтому разделяются между копиями.
+func (s *Session) clone() *Session {
+	c := *s
+	c.Selected = append([]int(nil), s.Selected...)
+	c.Answers = append([]AnswerRecord(nil), s.Answers...)
+	return &c
+}
+
+// --- ХРАНИЛИЩЕ В ПАМЯТИ ---
+
+// MemorySessionStore хранит сессии в памяти процесса
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[SessionKey]*Session
+}
+
+// NewMemorySessionStore создает пустое хранилище сессий в памяти
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[SessionKey]*Session)}
+}
+
+func (m *MemorySessionStore) Get(key SessionKey) (*Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[key]
+	if !ok {
+		return nil, false
+	}
+	return session.clone(), true
+}
+
+func (m *MemorySessionStore) Put(session *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[session.Key()] = session.clone()
+	return nil
+}
+
+func (m *MemorySessionStore) Update(key SessionKey, fn func(*Session) error) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.update(key, fn)
+}
+
+func (m *MemorySessionStore) Delete(key SessionKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, key)
+	return nil
+}
+
+// update выполняет Update; вызывающий должен держать m.mu
+func (m *MemorySessionStore) update(key SessionKey, fn func(*Session) error) (*Session, error) {
+	current, ok := m.sessions[key]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	updated := current.clone()
+	if err := fn(updated); err != nil {
+		return nil, err
+	}
+	m.sessions[key] = updated
+	return updated.clone(), nil
+}
+
+// --- ХРАНИЛИЩЕ В ФАЙЛЕ ---
+
+// FileSessionStore держит сессии в памяти и после каждого изменения
+// сохраняет их JSON-снимок на диск, чтобы попытки переживали перезапуск бота.
+type FileSessionStore struct {
+	mem  *MemorySessionStore
+	path string
+}
+
+// NewFileSessionStore загружает снимок сессий из path (если он есть) и возвращает хранилище
+func NewFileSessionStore(path string) (*FileSessionStore, error) {
+	store := &FileSessionStore{mem: NewMemorySessionStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл сессий %s: %w", path, err)
+	}
+
+	var snapshot []*Session
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать файл сессий %s: %w", path, err)
+	}
+	for _, session := range snapshot {
+		store.mem.sessions[session.Key()] = session
+	}
+	return store, nil
+}
+
+func (f *FileSessionStore) Get(key SessionKey) (*Session, bool) {
+	return f.mem.Get(key)
+}
+
+func (f *FileSessionStore) Put(session *Session) error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+
+	f.mem.sessions[session.Key()] = session.clone()
+	return f.save()
+}
+
+func (f *FileSessionStore) Update(key SessionKey, fn func(*Session) error) (*Session, error) {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+
+	updated, err := f.mem.update(key, fn)
+	if err != nil {
+		return nil, err
+	}
+	return updated, f.save()
+}
+
+func (f *FileSessionStore) Delete(key SessionKey) error {
+	f.mem.mu.Lock()
+	defer f.mem.mu.Unlock()
+
+	delete(f.mem.sessions, key)
+	return f.save()
+}
+
+// save атомарно перезаписывает снимок: пишет во временный файл и переименовывает его.
+// Вызывающий должен держать f.mem.mu.
+func (f *FileSessionStore) save() error {
+	snapshot := make([]*Session, 0, len(f.mem.sessions))
+	for _, session := range f.mem.sessions {
+		snapshot = append(snapshot, session)
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать сессии: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("не удалось создать временный файл сессий: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("не удалось записать файл сессий: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("не удалось записать файл сессий: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("не удалось сохранить файл сессий %s: %w", f.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// forEachSessionStore запускает test для хранилища в памяти и хранилища в файле
+func forEachSessionStore(t *testing.T, test func(t *testing.T, store SessionStore)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemorySessionStore())
+	})
+	t.Run("file", func(t *testing.T) {
+		store, err := NewFileSessionStore(filepath.Join(t.TempDir(), "sessions.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, store)
+	})
+}
+
+func newStoredSession(userID int64) *Session {
+	return &Session{
+		ChatID:    100,
+		UserID:    userID,
+		Username:  "student",
+		TenantID:  "class",
+		AttemptID: "a1",
+		TestName:  "Тест",
+		Questions: []TestQuestion{
+			{ID: "1", Question: "2+2?", Options: []string{"3", "4"}, CorrectAnswers: []int{2}},
+		},
+		StartedAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSessionStoreConcurrentUpdates(t *testing.T) {
+	forEachSessionStore(t, func(t *testing.T, store SessionStore) {
+		const users, updates = 4, 50
+
+		for userID := int64(1); userID <= users; userID++ {
+			if err := store.Put(newStoredSession(userID)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var wg sync.WaitGroup
+		for userID := int64(1); userID <= users; userID++ {
+			key := SessionKey{ChatID: 100, UserID: userID}
+			for i := 0; i < updates; i++ {
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					_, err := store.Update(key, func(s *Session) error {
+						s.Answers = append(s.Answers, AnswerRecord{QuestionID: "1", Points: 1})
+						s.ToggleSelected(i % 3)
+						return nil
+					})
+					if err != nil {
+						t.Error(err)
+					}
+				}()
+				go func() {
+					defer wg.Done()
+					// Копию можно менять без блокировок: хранилище это не затрагивает
+					if session, ok := store.Get(key); ok {
+						session.Answers = append(session.Answers, AnswerRecord{QuestionID: "копия"})
+						session.Selected = append(session.Selected, 99)
+					}
+				}()
+			}
+		}
+		wg.Wait()
+
+		for userID := int64(1); userID <= users; userID++ {
+			session, ok := store.Get(SessionKey{ChatID: 100, UserID: userID})
+			if !ok {
+				t.Fatalf("сессия пользователя %d потеряна", userID)
+			}
+			if len(session.Answers) != updates {
+				t.Errorf("пользователь %d: ответов %d, ожидалось %d", userID, len(session.Answers), updates)
+			}
+			for _, answer := range session.Answers {
+				if answer.QuestionID != "1" {
+					t.Fatalf("пользователь %d: изменение копии попало в хранилище", userID)
+				}
+			}
+		}
+	})
+}
+
+func TestSessionStoreUpdateError(t *testing.T) {
+	forEachSessionStore(t, func(t *testing.T, store SessionStore) {
+		key := SessionKey{ChatID: 100, UserID: 1}
+		if _, err := store.Update(key, func(*Session) error { return nil }); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("Update без сессии: %v, ожидалась ErrSessionNotFound", err)
+		}
+
+		store.Put(newStoredSession(1))
+		failure := errors.New("отказ")
+		_, err := store.Update(key, func(s *Session) error {
+			s.Position = 5
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Fatalf("Update = %v, ожидалась ошибка fn", err)
+		}
+		if session, _ := store.Get(key); session.Position != 0 {
+			t.Error("изменения отмененного Update сохранены")
+		}
+	})
+}
+
+func TestFileSessionStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	store, err := NewFileSessionStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept := newStoredSession(1)
+	removed := newStoredSession(2)
+	for _, session := range []*Session{kept, removed} {
+		if err := store.Put(session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	updated, err := store.Update(kept.Key(), func(s *Session) error {
+		s.Answer([]int{2})
+		s.LastActivity = time.Date(2026, 10, 1, 12, 5, 0, 0, time.UTC)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(removed.Key()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Перезапуск бота: новое хранилище читает снимок с диска
+	reloaded, err := NewFileSessionStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, ok := reloaded.Get(kept.Key())
+	if !ok {
+		t.Fatal("после перезапуска сессия не найдена")
+	}
+	if !reflect.DeepEqual(session, updated) {
+		t.Errorf("после перезапуска сессия = %+v, ожидалась %+v", session, updated)
+	}
+	if _, ok := reloaded.Get(removed.Key()); ok {
+		t.Error("после перезапуска вернулась удаленная сессия")
+	}
+}
+
+func TestFileSessionStoreRejectsBrokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileSessionStore(path); err == nil {
+		t.Error("поврежденный файл сессий прочитан без ошибки")
+	}
+}
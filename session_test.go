@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+func TestSessionAnswerPoints(t *testing.T) {
+	single := TestQuestion{ID: "1", Type: QuestionSingle, Options: []string{"а", "б", "в"}, CorrectAnswers: []int{2}}
+	weighted := single
+	weighted.Points = 3
+	multi := TestQuestion{ID: "2", Type: QuestionMulti, Options: []string{"а", "б", "в", "г"}, CorrectAnswers: []int{1, 3}, Points: 2}
+
+	tests := []struct {
+		name       string
+		question   TestQuestion
+		settings   TestSettings
+		selected   []int
+		wantCredit float64
+		wantPoints float64
+	}{
+		{name: "верный ответ", question: single, selected: []int{2}, wantCredit: 1, wantPoints: 1},
+		{name: "неверный ответ без штрафа", question: single, selected: []int{1}},
+		{name: "вес вопроса", question: weighted, selected: []int{2}, wantCredit: 1, wantPoints: 3},
+		{name: "штраф", question: single, settings: TestSettings{WrongPenalty: 0.25}, selected: []int{1}, wantPoints: -0.25},
+		{name: "штраф в долях веса", question: weighted, settings: TestSettings{WrongPenalty: 0.5}, selected: []int{3}, wantPoints: -1.5},
+		{name: "верный ответ не штрафуется", question: weighted, settings: TestSettings{WrongPenalty: 0.5}, selected: []int{2}, wantCredit: 1, wantPoints: 3},
+		{name: "несколько вариантов: все или ничего", question: multi, selected: []int{1}},
+		{name: "несколько вариантов: частичный балл", question: multi, settings: TestSettings{Scoring: ScoringPartial}, selected: []int{1}, wantCredit: 0.5, wantPoints: 1},
+		// Частично верный ответ не штрафуется, штраф только за ответ без засчитанной доли
+		{name: "частичный балл и штраф", question: multi, settings: TestSettings{Scoring: ScoringPartial, WrongPenalty: 0.5}, selected: []int{1}, wantCredit: 0.5, wantPoints: 1},
+		{name: "ошибки съедают частичный балл", question: multi, settings: TestSettings{Scoring: ScoringPartial, WrongPenalty: 0.5}, selected: []int{1, 2}, wantPoints: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &Session{Questions: []TestQuestion{tt.question}, Settings: tt.settings}
+			credit := session.Answer(tt.selected)
+			if credit != tt.wantCredit || session.Score != tt.wantPoints {
+				t.Errorf("Answer(%v) = доля %v, баллы %v; ожидалось %v, %v", tt.selected, credit, session.Score, tt.wantCredit, tt.wantPoints)
+			}
+			if got := session.Answers[0].Points; got != tt.wantPoints {
+				t.Errorf("в ответе записано %v баллов, ожидалось %v", got, tt.wantPoints)
+			}
+		})
+	}
+}
+
+func TestSessionFinalScore(t *testing.T) {
+	questions := []TestQuestion{
+		{ID: "1", Options: []string{"а", "б"}, CorrectAnswers: []int{1}, Points: 2},
+		{ID: "2", Options: []string{"а", "б"}, CorrectAnswers: []int{1}},
+		{ID: "3", Options: []string{"а", "б"}, CorrectAnswers: []int{1}},
+	}
+	tests := []struct {
+		name      string
+		answers   [][]int
+		wantScore float64
+		wantFinal float64
+	}{
+		{name: "все верно", answers: [][]int{{1}, {1}, {1}}, wantScore: 4, wantFinal: 4},
+		{name: "штраф уменьшает балл", answers: [][]int{{1}, {2}, {1}}, wantScore: 2.5, wantFinal: 2.5},
+		{name: "итог не меньше нуля", answers: [][]int{{2}, {2}, {2}}, wantScore: -2, wantFinal: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &Session{Questions: questions, Settings: TestSettings{WrongPenalty: 0.5}}
+			for _, selected := range tt.answers {
+				session.Answer(selected)
+			}
+			if !session.Finished() {
+				t.Fatal("попытка не завершена после ответа на все вопросы")
+			}
+			if session.Score != tt.wantScore || session.FinalScore() != tt.wantFinal {
+				t.Errorf("балл %v, итог %v; ожидалось %v, %v", session.Score, session.FinalScore(), tt.wantScore, tt.wantFinal)
+			}
+			if got := session.MaxScore(); got != 4 {
+				t.Errorf("MaxScore = %v, ожидалось 4", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScoringMode — как начисляются баллы за вопрос с несколькими верными вариантами
+type ScoringMode string
+
+const (
+	// ScoringAllOrNothing — балл только за точное совпадение выбранных вариантов с верными
+	ScoringAllOrNothing ScoringMode = "all"
+	// ScoringPartial — доля балла: (верно выбранные − ошибочно выбранные) / число верных, не меньше нуля
+	ScoringPartial ScoringMode = "partial"
+)
+
+// FeedbackMode — когда ученик узнает, верно ли он ответил
+type FeedbackMode string
+
+const (
+	// FeedbackNone — только итоговый балл (по умолчанию)
+	FeedbackNone FeedbackMode = "none"
+	// FeedbackInstant — верно/неверно и пояснение сразу после каждого ответа
+	FeedbackInstant FeedbackMode = "instant"
+	// FeedbackReview — разбор всех вопросов после завершения теста
+	FeedbackReview FeedbackMode = "review"
+)
+
+// AttemptCounting — какая попытка засчитывается в результатах теста и в Leaderboard
+type AttemptCounting string
+
+const (
+	// CountBest — лучшая попытка (по умолчанию)
+	CountBest AttemptCounting = "best"
+	// CountLatest — последняя попытка
+	CountLatest AttemptCounting = "latest"
+	// CountAverage — средний балл всех попыток
+	CountAverage AttemptCounting = "average"
+	// CountFirst — первая попытка; пересдачи в результатах не учитываются
+	CountFirst AttemptCounting = "first"
+)
+
+// replaces сообщает, заменяет ли новый результат с баллом score сохраненный результат с баллом previous
+func (c AttemptCounting) replaces(previous, score float64) bool {
+	switch c {
+	case CountFirst:
+		return false
+	case CountLatest, CountAverage:
+		return true
+	default:
+		return score > previous
+	}
+}
+
+// TestSettings — настройки теста из диапазона настроек вкладки (по умолчанию S2:T).
+// Каждая строка — пара "параметр | значение"; незаполненные параметры берутся по умолчанию.
+type TestSettings struct {
+	Scoring ScoringMode
+	// TypoTolerance — сколько опечаток допускается в свободном ответе (0 — только точное совпадение)
+	TypoTolerance int
+	// NumericTolerance — допустимое отклонение числового свободного ответа
+	NumericTolerance float64
+	// QuestionTimeLimit и TestTimeLimit — время на один вопрос и на весь тест (0 — без ограничения)
+	QuestionTimeLimit time.Duration
+	TestTimeLimit     time.Duration
+	// ShuffleQuestions и ShuffleOptions перемешивают вопросы и варианты в каждой попытке
+	ShuffleQuestions bool
+	ShuffleOptions   bool
+	// QuestionCount — сколько случайных вопросов выбрать из всех вопросов вкладки (0 — все)
+	QuestionCount int
+	// Feedback — показывать ли правильные ответы и пояснения
+	Feedback FeedbackMode
+	// MaxAttempts — сколько раз можно пройти тест (0 — без ограничения)
+	MaxAttempts int
+	// RetakeCooldown — сколько ждать после завершения попытки перед следующей
+	RetakeCooldown time.Duration
+	// CountedAttempt — какая попытка засчитывается
+	CountedAttempt AttemptCounting
+	// WrongPenalty — штраф за неверный ответ, доля веса вопроса (0 — без штрафа, 1 — минус вес вопроса)
+	WrongPenalty float64
+	// PassPercent — проходной процент от максимального балла (0 — зачет при любом результате)
+	PassPercent float64
+}
+
+// Passed сообщает, что результат score из maxScore набирает проходной процент
+func (s TestSettings) Passed(score, maxScore float64) bool {
+	return scorePercent(score, maxScore) >= s.PassPercent
+}
+
+// Test — загруженный тест: вопросы и настройки
+type Test struct {
+	Name      string
+	Questions []TestQuestion
+	Settings  TestSettings
+	// Problems — ошибки в ячейках, найденные при разборе: строки с ошибками в тест не попали
+	Problems []error
+}
+
+// parseTest разбирает вопросы и настройки теста name из строк диапазонов вопросов и настроек.
+// Строки с ошибками пропускаются, а ошибки сохраняются в Problems.
+func parseTest(name string, questions, settings sheetTable, layout questionLayout) Test {
+	test := Test{Name: name}
+	var problems, settingsProblems []error
+	test.Questions, problems = parseTestRows(questions, layout)
+	test.Settings, settingsProblems = parseTestSettings(settings)
+	test.Problems = append(problems, settingsProblems...)
+	return test
+}
+
+// defaultTestSettings возвращает настройки теста по умолчанию
+func defaultTestSettings() TestSettings {
+	return TestSettings{Scoring: ScoringAllOrNothing, Feedback: FeedbackNone, CountedAttempt: CountBest}
+}
+
+// parseTestSettings разбирает строки диапазона настроек. Неизвестные параметры и
+// некорректные значения пропускаются, а их ошибки возвращаются с адресом ячейки.
+func parseTestSettings(table sheetTable) (TestSettings, []error) {
+	settings := defaultTestSettings()
+	var problems []error
+	for i := range table.Rows {
+		d := table.decoder(i)
+		key := strings.ToLower(strings.TrimSpace(d.Text(0)))
+		value := strings.ToLower(strings.TrimSpace(d.Text(1)))
+		if key == "" {
+			continue
+		}
+		parseTestSetting(&settings, d, key, value)
+		problems = append(problems, d.Errors()...)
+	}
+	return settings, problems
+}
+
+// parseTestSetting применяет к settings параметр key со значением value из строки d
+func parseTestSetting(settings *TestSettings, d *rowDecoder, key, value string) {
+	switch key {
+	case "scoring":
+		switch ScoringMode(value) {
+		case ScoringAllOrNothing, ScoringPartial:
+			settings.Scoring = ScoringMode(value)
+		default:
+			d.Fail(1, "неверное значение настройки scoring (ожидается all или partial): %q", value)
+		}
+	case "feedback":
+		switch FeedbackMode(value) {
+		case FeedbackNone, FeedbackInstant, FeedbackReview:
+			settings.Feedback = FeedbackMode(value)
+		default:
+			d.Fail(1, "неверное значение настройки feedback (ожидается none, instant или review): %q", value)
+		}
+	case "typo_tolerance":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			d.Fail(1, "неверное значение настройки typo_tolerance (ожидается целое число от 0): %q", value)
+			return
+		}
+		settings.TypoTolerance = n
+	case "numeric_tolerance":
+		tolerance, err := parsePoints(value)
+		if err != nil || tolerance < 0 {
+			d.Fail(1, "неверное значение настройки numeric_tolerance (ожидается неотрицательное число): %q", value)
+			return
+		}
+		settings.NumericTolerance = tolerance
+	case "question_time_limit", "test_time_limit", "retake_cooldown":
+		limit, err := parseTimeLimit(value)
+		if err != nil {
+			d.Fail(1, "неверное значение настройки %s (ожидается число секунд или длительность вида 1m30s): %q", key, value)
+			return
+		}
+		switch key {
+		case "question_time_limit":
+			settings.QuestionTimeLimit = limit
+		case "test_time_limit":
+			settings.TestTimeLimit = limit
+		default:
+			settings.RetakeCooldown = limit
+		}
+	case "shuffle_questions", "shuffle_options":
+		enabled, ok := parseSwitch(value)
+		if !ok {
+			d.Fail(1, "неверное значение настройки %s (ожидается yes или no): %q", key, value)
+			return
+		}
+		if key == "shuffle_questions" {
+			settings.ShuffleQuestions = enabled
+		} else {
+			settings.ShuffleOptions = enabled
+		}
+	case "question_count", "max_attempts":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			d.Fail(1, "неверное значение настройки %s (ожидается целое число от 0): %q", key, value)
+			return
+		}
+		if key == "question_count" {
+			settings.QuestionCount = n
+		} else {
+			settings.MaxAttempts = n
+		}
+	case "wrong_penalty":
+		penalty, err := parsePercentOrFraction(value)
+		if err != nil || penalty < 0 {
+			d.Fail(1, "неверное значение настройки wrong_penalty (ожидается неотрицательное число, доля веса вопроса): %q", value)
+			return
+		}
+		settings.WrongPenalty = penalty
+	case "pass_percent":
+		// Ячейка с процентным форматом ("60%") читается числом 0.6: значения до 1 считаются долей
+		percent, err := parsePercentOrFraction(value)
+		if err == nil && (strings.HasSuffix(value, "%") || percent <= 1) {
+			percent = roundPoints(percent * 100)
+		}
+		if err != nil || percent < 0 || percent > 100 {
+			d.Fail(1, "неверное значение настройки pass_percent (ожидается число от 0 до 100): %q", value)
+			return
+		}
+		settings.PassPercent = percent
+	case "counted_attempt":
+		switch AttemptCounting(value) {
+		case CountBest, CountLatest, CountAverage, CountFirst:
+			settings.CountedAttempt = AttemptCounting(value)
+		default:
+			d.Fail(1, "неверное значение настройки counted_attempt (ожидается best, latest, average или first): %q", value)
+		}
+	default:
+		d.Fail(0, "неизвестная настройка теста %q", key)
+	}
+}
+
+// parsePercentOrFraction разбирает число; значение со знаком процента ("25%") переводится в долю (0.25)
+func parsePercentOrFraction(value string) (float64, error) {
+	if number, ok := strings.CutSuffix(value, "%"); ok {
+		percent, err := parsePoints(number)
+		return percent / 100, err
+	}
+	return parsePoints(value)
+}
+
+// parseTimeLimit разбирает ограничение времени: число секунд ("30") или длительность ("1m30s")
+func parseTimeLimit(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	limit, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if limit < 0 {
+		return 0, fmt.Errorf("отрицательное ограничение времени %s", value)
+	}
+	return limit, nil
+}
+
+// parseSwitch разбирает значение настройки-переключателя: yes/no, true/false, 1/0, да/нет
+func parseSwitch(value string) (enabled bool, ok bool) {
+	switch value {
+	case "yes", "true", "1", "on", "да":
+		return true, true
+	case "no", "false", "0", "off", "нет":
+		return false, true
+	default:
+		return false, false
+	}
+}
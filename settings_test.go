@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTestSettings(t *testing.T) {
+	settingsRange, err := parseA1Range("S2:T")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		rows [][]interface{}
+		// want изменяет настройки по умолчанию так, как их должны изменить строки
+		want     func(s *TestSettings)
+		wantCell string
+	}{
+		{name: "без настроек", want: func(s *TestSettings) {}},
+		{name: "проходной процент числом", rows: [][]interface{}{{"pass_percent", 60.0}}, want: func(s *TestSettings) { s.PassPercent = 60 }},
+		{name: "проходной процент со знаком", rows: [][]interface{}{{"pass_percent", "60%"}}, want: func(s *TestSettings) { s.PassPercent = 60 }},
+		// Ячейка с процентным форматом хранит 60% как 0.6
+		{name: "проходной процент долей", rows: [][]interface{}{{"pass_percent", 0.6}}, want: func(s *TestSettings) { s.PassPercent = 60 }},
+		{name: "проходной процент долей с запятой", rows: [][]interface{}{{"pass_percent", "0,75"}}, want: func(s *TestSettings) { s.PassPercent = 75 }},
+		{name: "дробный проходной процент", rows: [][]interface{}{{"pass_percent", 62.5}}, want: func(s *TestSettings) { s.PassPercent = 62.5 }},
+		{name: "проходной процент больше 100", rows: [][]interface{}{{"pass_percent", 150.0}}, want: func(s *TestSettings) {}, wantCell: "T2"},
+		{name: "штраф долей", rows: [][]interface{}{{"wrong_penalty", 0.25}}, want: func(s *TestSettings) { s.WrongPenalty = 0.25 }},
+		{name: "штраф в процентах", rows: [][]interface{}{{"wrong_penalty", "25%"}}, want: func(s *TestSettings) { s.WrongPenalty = 0.25 }},
+		{name: "отрицательный штраф", rows: [][]interface{}{{"wrong_penalty", -1.0}}, want: func(s *TestSettings) {}, wantCell: "T2"},
+		{name: "регистр и пробелы", rows: [][]interface{}{{" Counted_Attempt ", "Latest"}}, want: func(s *TestSettings) { s.CountedAttempt = CountLatest }},
+		{name: "ограничения времени", rows: [][]interface{}{{"question_time_limit", 30.0}, {"test_time_limit", "10m"}, {"retake_cooldown", "24h"}}, want: func(s *TestSettings) {
+			s.QuestionTimeLimit = 30 * time.Second
+			s.TestTimeLimit = 10 * time.Minute
+			s.RetakeCooldown = 24 * time.Hour
+		}},
+		{name: "переключатели", rows: [][]interface{}{{"shuffle_questions", "да"}, {"shuffle_options", true}}, want: func(s *TestSettings) {
+			s.ShuffleQuestions = true
+			s.ShuffleOptions = true
+		}},
+		{name: "пустые строки пропускаются", rows: [][]interface{}{{}, {"", "yes"}, {"max_attempts", 3.0}}, want: func(s *TestSettings) { s.MaxAttempts = 3 }},
+		// Ошибка в одной строке не мешает остальным настройкам
+		{name: "неизвестная настройка", rows: [][]interface{}{{"max_attempts", 3.0}, {"passing", 60.0}}, want: func(s *TestSettings) { s.MaxAttempts = 3 }, wantCell: "S3"},
+		{name: "неверный режим", rows: [][]interface{}{{"scoring", "half"}, {"feedback", "review"}}, want: func(s *TestSettings) { s.Feedback = FeedbackReview }, wantCell: "T2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, problems := parseTestSettings(sheetTable{Sheet: "Тест", Range: settingsRange, Rows: tt.rows})
+			want := defaultTestSettings()
+			tt.want(&want)
+			if got != want {
+				t.Errorf("настройки = %+v\nожидалось %+v", got, want)
+			}
+			if tt.wantCell == "" {
+				if problems != nil {
+					t.Errorf("неожиданные ошибки: %v", problems)
+				}
+				return
+			}
+			var cellErr *CellError
+			if len(problems) != 1 || !errors.As(problems[0], &cellErr) || cellErr.Cell != tt.wantCell {
+				t.Errorf("ошибки %v, ожидалась одна ошибка в ячейке %s", problems, tt.wantCell)
+			}
+		})
+	}
+}
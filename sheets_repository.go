@@ -0,0 +1,1020 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/sheets/v4"
+)
+
+// SheetsRepository хранит данные в Google Sheets: вопросы (по умолчанию A2:F), результаты (H2:Q)
+// и настройки (S2:T) во вкладке теста, рейтинг во вкладке Leaderboard, профиль во вкладке Teacher,
+// историю попыток во вкладках Attempts и Answers, описания тестов во вкладке Tests.
+// Названия вкладок и диапазоны задаются в SheetsConfig.
+type SheetsRepository struct {
+	service       *sheets.Service
+	cfg           SheetsConfig
+	spreadsheetID string
+	questions     a1Range
+	results       a1Range
+	settings      a1Range
+	layout        questionLayout
+
+	// leaderboardMutex не дает пересчету Leaderboard пересекаться с его чтением
+	leaderboardMutex sync.Mutex
+
+	// historyMutex защищает historyReady — признак того, что вкладки Attempts и Answers уже созданы
+	historyMutex sync.Mutex
+	historyReady bool
+
+	// history — попытки из вкладок Attempts и Answers, прочитанные в historyLoadedAt. Бот обращается
+	// к истории при каждом выборе и завершении теста, поэтому вкладки читаются не чаще раза в historyCacheTTL.
+	// historyCacheMutex не дает двум чтениям истории выполняться одновременно.
+	historyCacheMutex sync.Mutex
+	history           []Attempt
+	historyLoadedAt   time.Time
+}
+
+// historyCacheTTL — сколько прочитанная история попыток используется без повторного чтения.
+// Свои попытки бот сам добавляет в прочитанную историю, поэтому перечитывать вкладки нужно только
+// ради правок, сделанных в таблице вручную.
+const historyCacheTTL = 5 * time.Minute
+
+// NewSheetsRepository создает репозиторий поверх таблицы, описанной в cfg
+func NewSheetsRepository(service *sheets.Service, cfg SheetsConfig) (*SheetsRepository, error) {
+	questions, err := parseA1Range(cfg.QuestionsRange)
+	if err != nil {
+		return nil, fmt.Errorf("sheets.questions_range: %w", err)
+	}
+	results, err := parseA1Range(cfg.ResultsRange)
+	if err != nil {
+		return nil, fmt.Errorf("sheets.results_range: %w", err)
+	}
+	settings, err := parseA1Range(cfg.SettingsRange)
+	if err != nil {
+		return nil, fmt.Errorf("sheets.settings_range: %w", err)
+	}
+	layout, err := cfg.questionLayout()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SheetsRepository{
+		service:       service,
+		cfg:           cfg,
+		spreadsheetID: cfg.SpreadsheetID,
+		questions:     questions,
+		results:       results,
+		settings:      settings,
+		layout:        layout,
+	}, nil
+}
+
+// TestNames извлекает названия всех вкладок (листов) с тестами из таблицы.
+func (r *SheetsRepository) TestNames(ctx context.Context) ([]string, error) {
+	titles, err := r.sheetTitles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.testTitles(titles), nil
+}
+
+// testTitles отбирает из названий вкладок таблицы вкладки с тестами
+func (r *SheetsRepository) testTitles(titles []string) []string {
+	var testTitles []string
+	for _, title := range titles {
+		// 🚨 ФИЛЬТР: Исключаем служебные вкладки: Leaderboard, Results, Teacher, Attempts, Answers и Tests.
+		if r.cfg.isServiceSheet(title) {
+			continue
+		}
+
+		testTitles = append(testTitles, title)
+	}
+	return testTitles
+}
+
+// sheetTitles возвращает названия всех вкладок таблицы по порядку
+func (r *SheetsRepository) sheetTitles(ctx context.Context) ([]string, error) {
+	resp, err := r.service.Spreadsheets.Get(r.spreadsheetID).Context(ctx).Fields("sheets.properties.title").Do()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить свойства таблицы: %v", err)
+	}
+
+	titles := make([]string, 0, len(resp.Sheets))
+	for _, sheet := range resp.Sheets {
+		titles = append(titles, sheet.Properties.Title)
+	}
+	return titles, nil
+}
+
+// TestCatalog собирает тесты с описаниями из вкладки Tests. Если вкладки нет, показываются все тесты.
+// Список вкладок запрашивается один раз: по нему находятся и тесты, и вкладка Tests.
+func (r *SheetsRepository) TestCatalog(ctx context.Context) (TestCatalog, error) {
+	titles, err := r.sheetTitles(ctx)
+	if err != nil {
+		return TestCatalog{}, err
+	}
+	rows, err := r.readCatalogRows(ctx, titles)
+	if err != nil {
+		return TestCatalog{}, err
+	}
+	catalog := buildCatalog(r.testTitles(titles), sheetTable{Sheet: r.cfg.TestsSheet, Range: catalogRange(), Rows: rows})
+	logCellErrors(catalog.Problems)
+	return catalog, nil
+}
+
+// catalogRows читает строки вкладки Tests; если вкладки нет, возвращает пустой список
+func (r *SheetsRepository) catalogRows(ctx context.Context) ([][]interface{}, error) {
+	titles, err := r.sheetTitles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.readCatalogRows(ctx, titles)
+}
+
+// readCatalogRows читает строки вкладки Tests, если она есть среди вкладок titles
+func (r *SheetsRepository) readCatalogRows(ctx context.Context, titles []string) ([][]interface{}, error) {
+	if !slices.Contains(titles, r.cfg.TestsSheet) {
+		return nil, nil
+	}
+	values, err := r.batchGet(ctx, fmt.Sprintf("%s!%s", r.cfg.TestsSheet, catalogRange()))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать вкладку %s: %w", r.cfg.TestsSheet, err)
+	}
+	return values[0], nil
+}
+
+// LoadTest считывает вопросы, ответы и настройки из указанной вкладки (testName)
+func (r *SheetsRepository) LoadTest(ctx context.Context, testName string) (Test, error) {
+	values, err := r.batchGet(ctx,
+		fmt.Sprintf("%s!%s", testName, r.cfg.QuestionsRange),
+		fmt.Sprintf("%s!%s", testName, r.cfg.SettingsRange),
+	)
+	if err != nil {
+		return Test{}, fmt.Errorf("ошибка получения данных из Sheets (%s): %w", testName, err)
+	}
+
+	if len(values[0]) == 0 {
+		return Test{}, fmt.Errorf("во вкладке %s не найдено вопросов в диапазоне %s", testName, r.cfg.QuestionsRange)
+	}
+
+	test := parseTest(testName,
+		sheetTable{Sheet: testName, Range: r.questions, Rows: values[0]},
+		sheetTable{Sheet: testName, Range: r.settings, Rows: values[1]},
+		r.layout)
+	logCellErrors(test.Problems)
+	return test, nil
+}
+
+// batchGet читает диапазоны ranges одним запросом и возвращает их строки. Значения читаются
+// необработанными (UNFORMATTED_VALUE): числа приходят числами независимо от формата ячейки и локали,
+// а даты — текстом, как их показывает таблица.
+func (r *SheetsRepository) batchGet(ctx context.Context, ranges ...string) ([][][]interface{}, error) {
+	resp, err := r.service.Spreadsheets.Values.BatchGet(r.spreadsheetID).
+		Ranges(ranges...).
+		ValueRenderOption("UNFORMATTED_VALUE").
+		DateTimeRenderOption("FORMATTED_STRING").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return nil, err
+	}
+	values := make([][][]interface{}, len(ranges))
+	for i, valueRange := range resp.ValueRanges {
+		if i < len(values) {
+			values[i] = valueRange.Values
+		}
+	}
+	return values, nil
+}
+
+// SaveResult ищет предыдущий результат пользователя в той же вкладке и заменяет его,
+// если этого требует правило counting.
+func (r *SheetsRepository) SaveResult(ctx context.Context, result TestResult, counting AttemptCounting) error {
+	resultSheetName := result.TestName
+	userID := result.UserID
+	// Диапазон чтения: H2:Q
+	readRange := fmt.Sprintf("%s!%s", resultSheetName, r.cfg.ResultsRange)
+	// Диапазон записи: H:Q
+	writeRange := fmt.Sprintf("%s!%s", resultSheetName, r.results.Columns())
+
+	// Без прочитанных результатов нельзя найти строку пользователя: новая строка задвоила бы результат
+	values, err := r.batchGet(ctx, readRange)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать результаты из %s: %w", resultSheetName, err)
+	}
+
+	var updateCellRange string
+
+	if len(values) > 0 {
+		table := sheetTable{Sheet: resultSheetName, Range: r.results, Rows: values[0]}
+		for i, row := range table.Rows {
+			if cellText(row, 0) != strconv.FormatInt(userID, 10) {
+				continue
+			}
+
+			var previousScore float64
+			if previous, ok := decodeResult(table.decoder(i)); ok {
+				previousScore = previous.Score
+			}
+			if !counting.replaces(previousScore, result.Score) {
+				log.Printf("Результат пользователя %d (%s) в тесте %s не заменяет предыдущий (%s, засчитывается %s). Пропуск записи.", userID, formatPoints(result.Score), result.TestName, formatPoints(previousScore), counting)
+				return nil
+			}
+
+			updateCellRange = fmt.Sprintf("%s!%s", resultSheetName, r.results.Cell(i))
+			break
+		}
+	}
+
+	newScoreText := formatScore(result.Score, result.MaxScore)
+	valueRange := &sheets.ValueRange{
+		Values: [][]interface{}{resultRow(result)},
+	}
+
+	// RAW: числа записываются числами, и Sheets не превращает их в даты
+	if updateCellRange != "" {
+		_, err = r.service.Spreadsheets.Values.Update(r.spreadsheetID, updateCellRange, valueRange).
+			ValueInputOption("RAW").
+			Context(ctx).
+			Do()
+		log.Printf("Обновлен результат для пользователя %d в тесте %s: %s", userID, result.TestName, newScoreText)
+
+	} else {
+		_, err = r.service.Spreadsheets.Values.Append(r.spreadsheetID, writeRange, valueRange).
+			ValueInputOption("RAW").
+			InsertDataOption("INSERT_ROWS").
+			Context(ctx).
+			Do()
+		log.Printf("Записан новый результат для пользователя %d в тесте %s: %s", userID, result.TestName, newScoreText)
+	}
+
+	if err != nil {
+		return fmt.Errorf("ошибка записи/обновления результатов в %s: %w", resultSheetName, err)
+	}
+
+	return nil
+}
+
+// Колонки вкладок истории попыток (данные начинаются со второй строки, в первой — заголовки).
+// Новые колонки добавляются в конец, чтобы строки, записанные раньше, читались по-прежнему.
+var (
+	attemptsHeader = []interface{}{"Попытка", "Завершена", "UserID", "Username", "Тест", "Баллы", "Время", "Прервана", "Максимум", "Процент", "Зачет"}
+	answersHeader  = []interface{}{"Попытка", "ID вопроса", "Вопрос", "Ответ", "Баллы", "Время вышло", "Вес"}
+)
+
+// yesNo записывает логическое значение в ячейку: "да" или "нет"
+func yesNo(value bool) string {
+	if value {
+		return "да"
+	}
+	return "нет"
+}
+
+// attemptsRange и answersRange возвращают диапазоны данных вкладок Attempts и Answers
+func (r *SheetsRepository) attemptsRange() string {
+	return fmt.Sprintf("%s!A2:%s", r.cfg.AttemptsSheet, columnLetter(len(attemptsHeader)))
+}
+
+func (r *SheetsRepository) answersRange() string {
+	return fmt.Sprintf("%s!A2:%s", r.cfg.AnswersSheet, columnLetter(len(answersHeader)))
+}
+
+// ensureHistorySheets создает вкладки Attempts и Answers с заголовками, если их еще нет
+func (r *SheetsRepository) ensureHistorySheets(ctx context.Context) error {
+	r.historyMutex.Lock()
+	defer r.historyMutex.Unlock()
+
+	if r.historyReady {
+		return nil
+	}
+	for _, sheet := range []struct {
+		title  string
+		header []interface{}
+	}{
+		{r.cfg.AttemptsSheet, attemptsHeader},
+		{r.cfg.AnswersSheet, answersHeader},
+	} {
+		created, err := r.ensureSheet(ctx, sheet.title, 0)
+		if err != nil {
+			return err
+		}
+		if !created {
+			continue
+		}
+		header := &sheets.ValueRange{Values: [][]interface{}{sheet.header}}
+		if _, err := r.service.Spreadsheets.Values.Update(r.spreadsheetID, sheet.title+"!A1", header).
+			ValueInputOption("RAW").
+			Context(ctx).
+			Do(); err != nil {
+			return fmt.Errorf("не удалось записать заголовки вкладки %s: %w", sheet.title, err)
+		}
+	}
+	r.historyReady = true
+	return nil
+}
+
+// attemptRows превращает попытки в строки вкладок Attempts и Answers
+func attemptRows(attempts []Attempt) (attemptValues, answerValues [][]interface{}) {
+	for _, attempt := range attempts {
+		interrupted := ""
+		if attempt.Interrupted {
+			interrupted = "да"
+		}
+		attemptValues = append(attemptValues, []interface{}{
+			attempt.ID,
+			attempt.FinishedAt.Format("2006-01-02 15:04:05"),
+			attempt.UserID,
+			attempt.Username,
+			attempt.TestName,
+			attempt.Score,
+			formatDuration(attempt.Duration),
+			interrupted,
+			attempt.MaxScore,
+			roundPoints(attempt.Result().Percent()),
+			yesNo(attempt.Passed),
+		})
+		for _, answer := range attempt.Answers {
+			timedOut := ""
+			if answer.TimedOut {
+				timedOut = "да"
+			}
+			answerValues = append(answerValues, []interface{}{
+				attempt.ID,
+				answer.QuestionID,
+				answer.Question,
+				answer.Answer,
+				answer.Points,
+				timedOut,
+				answer.MaxPoints,
+			})
+		}
+	}
+	return attemptValues, answerValues
+}
+
+// SaveAttempt дописывает попытку в конец вкладки Attempts, а ее ответы — в конец вкладки Answers
+func (r *SheetsRepository) SaveAttempt(ctx context.Context, attempt Attempt) error {
+	if err := r.ensureHistorySheets(ctx); err != nil {
+		return err
+	}
+
+	attemptValues, answerValues := attemptRows([]Attempt{attempt})
+	// RAW, чтобы Sheets не превратил "3/5" в дату
+	for _, part := range []struct {
+		writeRange string
+		values     [][]interface{}
+	}{
+		{r.attemptsRange(), attemptValues},
+		{r.answersRange(), answerValues},
+	} {
+		if len(part.values) == 0 {
+			continue
+		}
+		_, err := r.service.Spreadsheets.Values.Append(r.spreadsheetID, part.writeRange, &sheets.ValueRange{Values: part.values}).
+			ValueInputOption("RAW").
+			InsertDataOption("INSERT_ROWS").
+			Context(ctx).
+			Do()
+		if err != nil {
+			return fmt.Errorf("ошибка записи попытки %s в %s: %w", attempt.ID, part.writeRange, err)
+		}
+	}
+	log.Printf("Попытка %s пользователя %d в тесте %s записана в историю", attempt.ID, attempt.UserID, attempt.TestName)
+	r.rememberAttempt(attempt)
+	return nil
+}
+
+// rememberAttempt добавляет записанную попытку в прочитанную историю, чтобы не перечитывать вкладки
+func (r *SheetsRepository) rememberAttempt(attempt Attempt) {
+	r.historyCacheMutex.Lock()
+	defer r.historyCacheMutex.Unlock()
+
+	// Если история еще не прочитана, попытка попадет в нее при первом чтении
+	if r.historyLoadedAt.IsZero() {
+		return
+	}
+	r.history = append(r.history, attempt)
+}
+
+// Attempts возвращает попытки, подходящие под запрос. История читается из вкладок Attempts и Answers
+// не чаще раза в historyCacheTTL (см. allAttempts).
+func (r *SheetsRepository) Attempts(ctx context.Context, query AttemptQuery) ([]Attempt, error) {
+	all, err := r.allAttempts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return selectAttempts(all, query), nil
+}
+
+// allAttempts возвращает всю историю попыток: прочитанную ранее, если она не старше historyCacheTTL,
+// или заново прочитанную из таблицы. Ошибка чтения не запоминается. Ответы попыток общие
+// с прочитанной историей, поэтому менять их нельзя.
+func (r *SheetsRepository) allAttempts(ctx context.Context) ([]Attempt, error) {
+	r.historyCacheMutex.Lock()
+	defer r.historyCacheMutex.Unlock()
+
+	if !r.historyLoadedAt.IsZero() && time.Since(r.historyLoadedAt) < historyCacheTTL {
+		return r.history, nil
+	}
+	attempts, err := r.readAttempts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.history, r.historyLoadedAt = attempts, time.Now()
+	return attempts, nil
+}
+
+// readAttempts читает вкладки Attempts и Answers и собирает все попытки с ответами
+func (r *SheetsRepository) readAttempts(ctx context.Context) ([]Attempt, error) {
+	// Вкладок истории может еще не быть, если ни одной попытки не записано: тогда история пуста.
+	// Остальные ошибки чтения возвращаются, чтобы временный сбой Sheets не выглядел как пустая история.
+	titles, err := r.sheetTitles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(titles, r.cfg.AttemptsSheet) {
+		return nil, nil
+	}
+	ranges := []string{r.attemptsRange()}
+	if slices.Contains(titles, r.cfg.AnswersSheet) {
+		ranges = append(ranges, r.answersRange())
+	}
+	values, err := r.batchGet(ctx, ranges...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать историю попыток: %w", err)
+	}
+	attemptValues := values[0]
+	var answerValues [][]interface{}
+	if len(values) > 1 {
+		answerValues = values[1]
+	}
+
+	var all []Attempt
+	var problems []error
+	attemptsTable := sheetTable{Sheet: r.cfg.AttemptsSheet, Range: historyRange(attemptsHeader), Rows: attemptValues}
+	for i := range attemptsTable.Rows {
+		d := attemptsTable.decoder(i)
+		if d.Empty() {
+			continue
+		}
+		if attempt, ok := decodeAttempt(d); ok {
+			all = append(all, attempt)
+		}
+		problems = append(problems, d.Errors()...)
+	}
+
+	byID := make(map[string]*Attempt, len(all))
+	for i := range all {
+		byID[all[i].ID] = &all[i]
+	}
+	answersTable := sheetTable{Sheet: r.cfg.AnswersSheet, Range: historyRange(answersHeader), Rows: answerValues}
+	for i := range answersTable.Rows {
+		d := answersTable.decoder(i)
+		attempt, ok := byID[d.Text(0)]
+		if !ok {
+			continue
+		}
+		answer := AttemptAnswer{
+			QuestionID: d.Text(1),
+			Question:   d.Text(2),
+			Answer:     d.Text(3),
+			Points:     d.Float(4, "Баллы"),
+			TimedOut:   d.Text(5) != "",
+			MaxPoints:  d.Float(6, "Вес"),
+		}
+		attempt.Answers = append(attempt.Answers, answer)
+		problems = append(problems, d.Errors()...)
+	}
+	logCellErrors(problems)
+	return all, nil
+}
+
+// historyRange возвращает диапазон данных вкладки истории с колонками header
+func historyRange(header []interface{}) a1Range {
+	return a1Range{StartColumn: "A", StartRow: 2, EndColumn: columnLetter(len(header))}
+}
+
+// decodeAttempt разбирает строку вкладки Attempts
+func decodeAttempt(d *rowDecoder) (Attempt, bool) {
+	attempt := Attempt{
+		ID:         d.RequiredText(0, "Попытка"),
+		FinishedAt: d.Time(1, "Завершена"),
+		UserID:     d.Int64(2, "UserID"),
+		Username:   d.Text(3),
+		TestName:   d.Text(4),
+		Duration:   d.Duration(6, "Время"),
+		// Прерванные попытки записываются с версии, где появилась колонка "Прервана"
+		Interrupted: d.Flag(7, "Прервана", false),
+		Passed:      d.Flag(10, "Зачет", true),
+	}
+	// В ранних записях балл хранился текстом "score/total" без отдельной колонки максимума
+	if score, maxScore, ok := parseScore(d.Text(5)); ok {
+		attempt.Score, attempt.MaxScore = score, maxScore
+	} else {
+		attempt.Score = d.Float(5, "Баллы")
+		attempt.MaxScore = d.Float(8, "Максимум")
+	}
+	return attempt, d.OK()
+}
+
+// UpdateLeaderboard агрегирует засчитываемый результат каждого пользователя по всем тестам и записывает в Leaderboard.
+func (r *SheetsRepository) UpdateLeaderboard(ctx context.Context) error {
+	r.leaderboardMutex.Lock()
+	defer r.leaderboardMutex.Unlock()
+
+	titles, err := r.sheetTitles(ctx)
+	if err != nil {
+		return fmt.Errorf("не удалось получить вкладки таблицы для Leaderboard: %w", err)
+	}
+
+	// Результаты и настройки (правило засчитывания попыток) всех тестов читаются одним запросом:
+	// для каждой вкладки с тестом — пара диапазонов подряд
+	testNames := r.testTitles(titles)
+	var ranges []string
+	for _, testName := range testNames {
+		ranges = append(ranges,
+			fmt.Sprintf("%s!%s", testName, r.cfg.ResultsRange),
+			fmt.Sprintf("%s!%s", testName, r.cfg.SettingsRange),
+		)
+	}
+
+	var results []TestResult
+	counting := make(map[string]AttemptCounting)
+	if len(ranges) > 0 {
+		values, err := r.batchGet(ctx, ranges...)
+		if err != nil {
+			return fmt.Errorf("не удалось прочитать результаты тестов для Leaderboard: %w", err)
+		}
+		for i, testName := range testNames {
+			// Leaderboard пересчитывается часто, поэтому строки с ошибками пропускаются без записи в лог
+			testResults, _ := parseResultRows(sheetTable{Sheet: testName, Range: r.results, Rows: values[2*i]})
+			results = append(results, testResults...)
+			settings, _ := parseTestSettings(sheetTable{Sheet: testName, Range: r.settings, Rows: values[2*i+1]})
+			counting[testName] = settings.CountedAttempt
+		}
+	}
+
+	aggregatedStats := aggregateLeaderboard(results, counting)
+
+	// Форматирование для записи
+	var values [][]interface{}
+	for _, stat := range aggregatedStats {
+		values = append(values, []interface{}{
+			stat.UserID,
+			stat.Username,
+			stat.TotalScore,
+			stat.TotalPassed,
+		})
+	}
+
+	// Очистка и запись в Leaderboard
+	clearRange := fmt.Sprintf("%s!%s", r.cfg.LeaderboardSheet, r.cfg.LeaderboardRange)
+	clearRequest := &sheets.ClearValuesRequest{}
+	if _, err := r.service.Spreadsheets.Values.Clear(r.spreadsheetID, clearRange, clearRequest).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("не удалось очистить Leaderboard: %w", err)
+	}
+
+	if len(values) > 0 {
+		valueRange := &sheets.ValueRange{
+			Values: values,
+		}
+
+		writeRange := fmt.Sprintf("%s!%s", r.cfg.LeaderboardSheet, r.cfg.LeaderboardRange)
+		_, err = r.service.Spreadsheets.Values.Update(r.spreadsheetID, writeRange, valueRange).
+			ValueInputOption("USER_ENTERED").
+			Context(ctx).
+			Do()
+
+		if err != nil {
+			return fmt.Errorf("ошибка записи в Leaderboard: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UserStats считывает статистику пользователя из Leaderboard.
+func (r *SheetsRepository) UserStats(ctx context.Context, userID int64) (UserStats, error) {
+	r.leaderboardMutex.Lock()
+	defer r.leaderboardMutex.Unlock()
+	stats := UserStats{TotalPassed: 0, TotalScore: 0}
+
+	// Читаем Leaderboard (A: UserID, B: Username, C: Score, D: Passed)
+	readRange := fmt.Sprintf("%s!%s", r.cfg.LeaderboardSheet, r.cfg.LeaderboardRange)
+	values, err := r.batchGet(ctx, readRange)
+	if err != nil {
+		return stats, fmt.Errorf("ошибка чтения Leaderboard: %w", err)
+	}
+	leaderboardRange, err := parseA1Range(r.cfg.LeaderboardRange)
+	if err != nil {
+		return stats, fmt.Errorf("sheets.leaderboard_range: %w", err)
+	}
+
+	userIDStr := strconv.FormatInt(userID, 10)
+	table := sheetTable{Sheet: r.cfg.LeaderboardSheet, Range: leaderboardRange, Rows: values[0]}
+
+	// Ищем пользователя по UserID в колонке A (индекс 0)
+	for i, row := range table.Rows {
+		if cellText(row, 0) != userIDStr {
+			continue
+		}
+		d := table.decoder(i)
+		stats.UserID = userIDStr
+		stats.Username = d.Text(1)
+		stats.TotalScore = d.Float(2, "Score")
+		stats.TotalPassed = d.Int(3, "Passed")
+		logCellErrors(d.Errors())
+		return stats, nil
+	}
+
+	return stats, nil
+}
+
+// TeacherProfile считывает информацию о преподавателе из вкладки Teacher
+func (r *SheetsRepository) TeacherProfile(ctx context.Context) (TeacherProfile, error) {
+	var profile TeacherProfile
+
+	// Колонка A (по умолчанию A2:A10) и колонка B с описанием (по умолчанию B2:B12)
+	values, err := r.batchGet(ctx,
+		fmt.Sprintf("%s!%s", r.cfg.TeacherSheet, r.cfg.TeacherInfoRange),
+		fmt.Sprintf("%s!%s", r.cfg.TeacherSheet, r.cfg.TeacherDescriptionRange),
+	)
+	if err != nil {
+		return profile, fmt.Errorf("ошибка получения профиля преподавателя из Sheets: %w", err)
+	}
+	info, description := values[0], values[1]
+
+	// 1. Чтение данных из столбца A
+
+	// Функция проверки наличия данных в строке:
+	getData := func(rowIndex int) string {
+		if rowIndex < len(info) {
+			return cellText(info[rowIndex], 0)
+		}
+		return ""
+	}
+
+	// A2 (индекс 0): Name
+	if name := getData(0); name != "" {
+		profile.Name = name
+	} else {
+		profile.Name = "Не указано"
+	}
+
+	// A4 (индекс 2): Photo URL
+	profile.PhotoURL = getData(2)
+
+	// A6 (индекс 4): Audio URL
+	profile.AudioURL = getData(4)
+
+	// A8 (индекс 6): Video URL
+	profile.VideoURL = getData(6)
+
+	// A10 (индекс 8): Contacts
+	if contacts := getData(8); contacts != "" {
+		profile.Contacts = contacts
+	} else {
+		profile.Contacts = "Не указано"
+	}
+
+	// 2. Чтение Описания из столбца B и объединение строк
+	var descriptionLines []string
+	for _, row := range description {
+		descriptionLines = append(descriptionLines, cellText(row, 0))
+	}
+
+	profile.Description = strings.Join(descriptionLines, "\n")
+
+	return profile, nil
+}
+
+// --- ПОЛНАЯ ВЫГРУЗКА И ЗАГРУЗКА (для синхронизации с SQLite) ---
+
+// testRows читает строки вопросов из диапазона вопросов вкладки теста
+func (r *SheetsRepository) testRows(ctx context.Context, testName string) ([][]interface{}, error) {
+	values, err := r.batchGet(ctx, fmt.Sprintf("%s!%s", testName, r.cfg.QuestionsRange))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения данных из Sheets (%s): %w", testName, err)
+	}
+	return values[0], nil
+}
+
+// testSettings читает строки настроек теста
+func (r *SheetsRepository) testSettings(ctx context.Context, testName string) ([][]interface{}, error) {
+	values, err := r.batchGet(ctx, fmt.Sprintf("%s!%s", testName, r.cfg.SettingsRange))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать настройки %s из вкладки %s: %w", r.cfg.SettingsRange, testName, err)
+	}
+	return values[0], nil
+}
+
+// testResults читает результаты из диапазона результатов вкладки теста
+func (r *SheetsRepository) testResults(ctx context.Context, testName string) ([]TestResult, error) {
+	values, err := r.batchGet(ctx, fmt.Sprintf("%s!%s", testName, r.cfg.ResultsRange))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать результаты %s из вкладки %s: %w", r.cfg.ResultsRange, testName, err)
+	}
+	results, problems := parseResultRows(sheetTable{Sheet: testName, Range: r.results, Rows: values[0]})
+	logCellErrors(problems)
+	return results, nil
+}
+
+// resultSchemaVersion — версия раскладки колонок результатов. Она записывается в последнюю колонку
+// каждой строки, чтобы строки старой раскладки можно было отличить и перенести (migrate-results).
+const resultSchemaVersion = 2
+
+// resultsHeader — колонки результатов теста (results_range) в схеме resultSchemaVersion
+var resultsHeader = []interface{}{"UserID", "Username", "Баллы", "Максимум", "Процент", "Зачет", "Завершен", "Длительность", "Попытка", "Схема"}
+
+// resultRow превращает результат в строку колонок результатов
+func resultRow(result TestResult) []interface{} {
+	return []interface{}{
+		result.UserID,
+		result.Username,
+		result.Score,
+		result.MaxScore,
+		roundPoints(result.Percent()),
+		yesNo(result.Passed),
+		result.FinishedAt.Format("2006-01-02 15:04:05"),
+		formatDuration(result.Duration),
+		result.Attempt,
+		resultSchemaVersion,
+	}
+}
+
+// resultRowVersion возвращает версию схемы строки результатов; у строк старой раскладки колонки версии нет
+func resultRowVersion(row []interface{}) int {
+	version, err := strconv.Atoi(strings.TrimSpace(cellText(row, len(resultsHeader)-1)))
+	if err != nil {
+		return 1
+	}
+	return version
+}
+
+// scoreFromDate восстанавливает результат "3/5", который Sheets превратил в дату: ячейка хранит
+// число дней с 30.12.1899, а балл и максимум стали месяцем и днем. В зависимости от языка таблицы
+// "3/5" — это 5 марта или 3 мая, но балл не больше максимума, поэтому меньшее из двух — балл.
+func scoreFromDate(row []interface{}, col int) (score, maxScore float64, ok bool) {
+	if col < 0 || col >= len(row) {
+		return 0, 0, false
+	}
+	days, isNumber := row[col].(float64)
+	// Дата, набранная без времени, — целое число дней; год ввода — заведомо позже 1900
+	if !isNumber || days != math.Trunc(days) || days <= 366 {
+		return 0, 0, false
+	}
+	date := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(days))
+	month, day := float64(date.Month()), float64(date.Day())
+	return min(month, day), max(month, day), true
+}
+
+// decodeResult разбирает строку результатов теста. Строки старой раскладки
+// (UserID, Username, "score/total", время, длительность) тоже читаются, чтобы результаты,
+// еще не перенесенные командой migrate-results, учитывались в Leaderboard; балл, который
+// Sheets превратил в дату, восстанавливается по scoreFromDate.
+func decodeResult(d *rowDecoder) (TestResult, bool) {
+	result := TestResult{
+		TestName: d.table.Sheet,
+		UserID:   d.Int64(0, "UserID"),
+		Username: d.Text(1),
+	}
+
+	if resultRowVersion(d.row) < resultSchemaVersion {
+		score, maxScore, ok := parseScore(d.Text(2))
+		if !ok {
+			score, maxScore, ok = scoreFromDate(d.row, 2)
+		}
+		if !ok {
+			d.Fail(2, "результат старой раскладки должен иметь вид 3/5, получено %q", d.Text(2))
+			return TestResult{}, false
+		}
+		result.Score, result.MaxScore = score, maxScore
+		// Зачет в старой раскладке не хранился
+		result.Passed = true
+		result.FinishedAt = d.Time(3, "Завершен")
+		result.Duration = d.Duration(4, "Длительность")
+		return result, d.OK()
+	}
+
+	result.Score = d.RequiredFloat(2, "Баллы")
+	result.MaxScore = d.Float(3, "Максимум")
+	result.Passed = d.Flag(5, "Зачет", true)
+	result.FinishedAt = d.Time(6, "Завершен")
+	result.Duration = d.Duration(7, "Длительность")
+	result.Attempt = d.Int(8, "Попытка")
+	return result, d.OK()
+}
+
+// parseResultRows разбирает строки результатов; пустые строки пропускаются, строки с ошибками —
+// тоже, а их ошибки возвращаются с адресом ячейки
+func parseResultRows(table sheetTable) ([]TestResult, []error) {
+	var results []TestResult
+	var problems []error
+	for i := range table.Rows {
+		d := table.decoder(i)
+		if d.Empty() {
+			continue
+		}
+		if result, ok := decodeResult(d); ok {
+			results = append(results, result)
+		}
+		problems = append(problems, d.Errors()...)
+	}
+	return results, problems
+}
+
+// replaceTest перезаписывает вопросы, настройки и результаты во вкладке теста, создавая вкладку при необходимости
+func (r *SheetsRepository) replaceTest(ctx context.Context, testName string, position int, rows, settings [][]interface{}, results []TestResult) error {
+	if _, err := r.ensureSheet(ctx, testName, position); err != nil {
+		return err
+	}
+
+	clear := &sheets.BatchClearValuesRequest{
+		Ranges: []string{
+			fmt.Sprintf("%s!%s", testName, r.cfg.QuestionsRange),
+			fmt.Sprintf("%s!%s", testName, r.cfg.ResultsRange),
+			fmt.Sprintf("%s!%s", testName, r.cfg.SettingsRange),
+		},
+	}
+	if _, err := r.service.Spreadsheets.Values.BatchClear(r.spreadsheetID, clear).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("не удалось очистить вкладку %s: %w", testName, err)
+	}
+
+	var resultRows [][]interface{}
+	for _, result := range results {
+		resultRows = append(resultRows, resultRow(result))
+	}
+
+	// RAW, чтобы Sheets не превратил числа и "3/5" в даты
+	update := &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "RAW",
+		Data: []*sheets.ValueRange{
+			{Range: fmt.Sprintf("%s!%s", testName, r.questions.Cell(0)), Values: rows},
+			{Range: fmt.Sprintf("%s!%s", testName, r.results.Cell(0)), Values: resultRows},
+			{Range: fmt.Sprintf("%s!%s", testName, r.settings.Cell(0)), Values: settings},
+		},
+	}
+	if header, ok := r.resultsHeaderRange(testName); ok {
+		update.Data = append(update.Data, &sheets.ValueRange{Range: header, Values: [][]interface{}{resultsHeader}})
+	}
+	if _, err := r.service.Spreadsheets.Values.BatchUpdate(r.spreadsheetID, update).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("не удалось записать вкладку %s: %w", testName, err)
+	}
+	return nil
+}
+
+// resultsHeaderRange возвращает ячейку заголовков результатов во вкладке testName — строку над results_range.
+// Если результаты начинаются с первой строки, места для заголовков нет.
+func (r *SheetsRepository) resultsHeaderRange(testName string) (string, bool) {
+	if r.results.StartRow < 2 {
+		return "", false
+	}
+	return fmt.Sprintf("%s!%s%d", testName, r.results.StartColumn, r.results.StartRow-1), true
+}
+
+// ensureSheet создает вкладку title, если ее еще нет в таблице. Возвращает true, если вкладка создана.
+func (r *SheetsRepository) ensureSheet(ctx context.Context, title string, position int) (bool, error) {
+	resp, err := r.service.Spreadsheets.Get(r.spreadsheetID).Context(ctx).Fields("sheets.properties.title").Do()
+	if err != nil {
+		return false, fmt.Errorf("не удалось получить свойства таблицы: %w", err)
+	}
+	for _, sheet := range resp.Sheets {
+		if sheet.Properties.Title == title {
+			return false, nil
+		}
+	}
+
+	add := &sheets.BatchUpdateSpreadsheetRequest{
+		Requests: []*sheets.Request{{
+			AddSheet: &sheets.AddSheetRequest{
+				Properties: &sheets.SheetProperties{Title: title, Index: int64(position)},
+			},
+		}},
+	}
+	if _, err := r.service.Spreadsheets.BatchUpdate(r.spreadsheetID, add).Context(ctx).Do(); err != nil {
+		return false, fmt.Errorf("не удалось создать вкладку %s: %w", title, err)
+	}
+	log.Printf("Создана вкладка %s", title)
+	return true, nil
+}
+
+// replaceCatalog перезаписывает описания тестов во вкладке Tests
+func (r *SheetsRepository) replaceCatalog(ctx context.Context, rows [][]interface{}) error {
+	// Пустые описания не создают вкладку Tests в таблице, где ее не было
+	if len(rows) == 0 {
+		existing, err := r.catalogRows(ctx)
+		if err != nil || len(existing) == 0 {
+			return err
+		}
+	}
+	created, err := r.ensureSheet(ctx, r.cfg.TestsSheet, 0)
+	if err != nil {
+		return err
+	}
+
+	catalog := fmt.Sprintf("%s!%s", r.cfg.TestsSheet, catalogRange())
+	clear := &sheets.BatchClearValuesRequest{Ranges: []string{catalog}}
+	if _, err := r.service.Spreadsheets.Values.BatchClear(r.spreadsheetID, clear).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("не удалось очистить вкладку %s: %w", r.cfg.TestsSheet, err)
+	}
+	update := &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "RAW",
+		Data:             []*sheets.ValueRange{{Range: catalog, Values: rows}},
+	}
+	if created {
+		update.Data = append(update.Data, &sheets.ValueRange{Range: r.cfg.TestsSheet + "!A1", Values: [][]interface{}{catalogHeader}})
+	}
+	if _, err := r.service.Spreadsheets.Values.BatchUpdate(r.spreadsheetID, update).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("не удалось записать вкладку %s: %w", r.cfg.TestsSheet, err)
+	}
+	return nil
+}
+
+// replaceAttempts перезаписывает вкладки Attempts и Answers
+func (r *SheetsRepository) replaceAttempts(ctx context.Context, attempts []Attempt) error {
+	if err := r.ensureHistorySheets(ctx); err != nil {
+		return err
+	}
+	// Даже если запись не удастся, вкладки уже могли измениться
+	defer r.forgetHistory()
+
+	clear := &sheets.BatchClearValuesRequest{Ranges: []string{r.attemptsRange(), r.answersRange()}}
+	if _, err := r.service.Spreadsheets.Values.BatchClear(r.spreadsheetID, clear).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("не удалось очистить историю попыток: %w", err)
+	}
+
+	attemptValues, answerValues := attemptRows(attempts)
+	update := &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "RAW",
+		Data: []*sheets.ValueRange{
+			{Range: r.attemptsRange(), Values: attemptValues},
+			{Range: r.answersRange(), Values: answerValues},
+		},
+	}
+	if _, err := r.service.Spreadsheets.Values.BatchUpdate(r.spreadsheetID, update).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("не удалось записать историю попыток: %w", err)
+	}
+	return nil
+}
+
+// forgetHistory сбрасывает прочитанную историю попыток: при следующем обращении она будет прочитана заново
+func (r *SheetsRepository) forgetHistory() {
+	r.historyCacheMutex.Lock()
+	defer r.historyCacheMutex.Unlock()
+
+	r.history, r.historyLoadedAt = nil, time.Time{}
+}
+
+// replaceTeacherProfile записывает профиль преподавателя в ячейки вкладки Teacher
+func (r *SheetsRepository) replaceTeacherProfile(ctx context.Context, profile TeacherProfile) error {
+	teacherSheet := r.cfg.TeacherSheet
+	if _, err := r.ensureSheet(ctx, teacherSheet, 0); err != nil {
+		return err
+	}
+
+	// A2: имя, A4: фото, A6: аудио, A8: видео, A10: контакты (см. TeacherProfile)
+	columnA := make([][]interface{}, 9)
+	for i := range columnA {
+		columnA[i] = []interface{}{""}
+	}
+	columnA[0][0] = profile.Name
+	columnA[2][0] = profile.PhotoURL
+	columnA[4][0] = profile.AudioURL
+	columnA[6][0] = profile.VideoURL
+	columnA[8][0] = profile.Contacts
+
+	var columnB [][]interface{}
+	for _, line := range strings.Split(profile.Description, "\n") {
+		columnB = append(columnB, []interface{}{line})
+	}
+
+	clear := &sheets.BatchClearValuesRequest{
+		Ranges: []string{
+			fmt.Sprintf("%s!%s", r.cfg.TeacherSheet, r.cfg.TeacherInfoRange),
+			fmt.Sprintf("%s!%s", r.cfg.TeacherSheet, r.cfg.TeacherDescriptionRange),
+		},
+	}
+	if _, err := r.service.Spreadsheets.Values.BatchClear(r.spreadsheetID, clear).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("не удалось очистить вкладку %s: %w", teacherSheet, err)
+	}
+
+	update := &sheets.BatchUpdateValuesRequest{
+		ValueInputOption: "RAW",
+		Data: []*sheets.ValueRange{
+			{Range: fmt.Sprintf("%s!%s", r.cfg.TeacherSheet, r.cfg.TeacherInfoRange), Values: columnA},
+			{Range: fmt.Sprintf("%s!%s", r.cfg.TeacherSheet, r.cfg.TeacherDescriptionRange), Values: columnB},
+		},
+	}
+	if _, err := r.service.Spreadsheets.Values.BatchUpdate(r.spreadsheetID, update).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("не удалось записать вкладку %s: %w", teacherSheet, err)
+	}
+	return nil
+}
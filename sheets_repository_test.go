@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+)
+
+// fakeSheets — таблица Google Sheets в памяти для тестов: отвечает на запросы, которые делает
+// SheetsRepository, и считает чтения. Значения хранятся по вкладке ("Тест") или по диапазону
+// ("Тест!H2:Q"); диапазон без своих значений читает значения вкладки. Записанные и очищенные
+// диапазоны хранятся по тому адресу, по которому их записали.
+type fakeSheets struct {
+	mu     sync.Mutex
+	values map[string][][]interface{}
+	// gets и batchGets — число запросов свойств таблицы и чтений значений
+	gets, batchGets int
+	// ranges — диапазоны последнего чтения значений
+	ranges []string
+	// failBatchGets и failClears — сколько следующих чтений и очисток значений завершится ошибкой
+	failBatchGets, failClears int
+}
+
+func newFakeSheets(values map[string][][]interface{}) *fakeSheets {
+	return &fakeSheets{values: values}
+}
+
+// titles возвращает названия вкладок, у которых есть значения
+func (f *fakeSheets) titles() []string {
+	seen := make(map[string]bool)
+	var titles []string
+	for key := range f.values {
+		title, _, _ := strings.Cut(key, "!")
+		if !seen[title] {
+			seen[title] = true
+			titles = append(titles, title)
+		}
+	}
+	return titles
+}
+
+// lookup возвращает значения диапазона valueRange
+func (f *fakeSheets) lookup(valueRange string) [][]interface{} {
+	if values, ok := f.values[valueRange]; ok {
+		return values
+	}
+	title, _, _ := strings.Cut(valueRange, "!")
+	return f.values[title]
+}
+
+func (f *fakeSheets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v4/spreadsheets/test")
+	valueRange, _ := url.PathUnescape(strings.TrimPrefix(path, "/values/"))
+	switch {
+	case r.Method == http.MethodGet && path == "":
+		f.gets++
+		var resp sheets.Spreadsheet
+		for _, title := range f.titles() {
+			resp.Sheets = append(resp.Sheets, &sheets.Sheet{Properties: &sheets.SheetProperties{Title: title}})
+		}
+		json.NewEncoder(w).Encode(resp)
+	case r.Method == http.MethodGet && path == "/values:batchGet":
+		f.batchGets++
+		f.ranges = r.URL.Query()["ranges"]
+		if f.failBatchGets > 0 {
+			f.failBatchGets--
+			http.Error(w, `{"error":{"code":503,"message":"unavailable"}}`, http.StatusServiceUnavailable)
+			return
+		}
+		var resp sheets.BatchGetValuesResponse
+		for _, valueRange := range f.ranges {
+			resp.ValueRanges = append(resp.ValueRanges, &sheets.ValueRange{Range: valueRange, Values: f.lookup(valueRange)})
+		}
+		json.NewEncoder(w).Encode(resp)
+	case r.Method == http.MethodPost && strings.HasSuffix(valueRange, ":clear"):
+		if f.failClears > 0 {
+			f.failClears--
+			http.Error(w, `{"error":{"code":503,"message":"unavailable"}}`, http.StatusServiceUnavailable)
+			return
+		}
+		f.values[strings.TrimSuffix(valueRange, ":clear")] = nil
+		json.NewEncoder(w).Encode(sheets.ClearValuesResponse{})
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/values/"):
+		var body sheets.ValueRange
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.values[valueRange] = body.Values
+		json.NewEncoder(w).Encode(sheets.UpdateValuesResponse{})
+	case r.Method == http.MethodPost && strings.HasSuffix(valueRange, ":append"):
+		title, _, _ := strings.Cut(strings.TrimSuffix(valueRange, ":append"), "!")
+		var body sheets.ValueRange
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.values[title] = append(f.values[title], body.Values...)
+		json.NewEncoder(w).Encode(sheets.AppendValuesResponse{})
+	case r.Method == http.MethodPost && path == "/values:batchClear":
+		var body sheets.BatchClearValuesRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, valueRange := range body.Ranges {
+			f.values[valueRange] = nil
+		}
+		json.NewEncoder(w).Encode(sheets.BatchClearValuesResponse{})
+	case r.Method == http.MethodPost && path == "/values:batchUpdate":
+		var body sheets.BatchUpdateValuesRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, data := range body.Data {
+			f.values[data.Range] = data.Values
+		}
+		json.NewEncoder(w).Encode(sheets.BatchUpdateValuesResponse{})
+	default:
+		http.Error(w, "неожиданный запрос "+r.Method+" "+r.URL.Path, http.StatusNotImplemented)
+	}
+}
+
+// counts возвращает число запросов свойств таблицы и чтений значений
+func (f *fakeSheets) counts() (gets, batchGets int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.gets, f.batchGets
+}
+
+// newFakeSheetsRepository создает SheetsRepository поверх таблицы fake
+func newFakeSheetsRepository(t *testing.T, fake *fakeSheets) *SheetsRepository {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	service, err := sheets.NewService(context.Background(),
+		option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := defaultConfig().Sheets
+	cfg.SpreadsheetID = "test"
+	repo, err := NewSheetsRepository(service, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func leaderboardSheets() map[string][][]interface{} {
+	return map[string][][]interface{}{
+		"Тест!H2:Q": {
+			{200, "student", 2, 2, 100, "да", "2026-10-01 12:00:00", "1m0s", 1, 2},
+			{200, "student", 1, 2, 50, "да", "2026-10-02 12:00:00", "1m0s", 2, 2},
+		},
+		"Тест!S2:T": {{"counted_attempt", "latest"}},
+		"Тест 2!H2:Q": {
+			{200, "student", 3, 3, 100, "да", "2026-10-01 12:00:00", "1m0s", 1, 2},
+			{300, "other", 1, 3, 33, "да", "2026-10-01 13:00:00", "1m0s", 1, 2},
+		},
+		"Leaderboard": nil,
+		"Attempts":    nil,
+	}
+}
+
+func TestSheetsUpdateLeaderboardReadsAllTestsAtOnce(t *testing.T) {
+	fake := newFakeSheets(leaderboardSheets())
+	repo := newFakeSheetsRepository(t, fake)
+
+	if err := repo.UpdateLeaderboard(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if gets, batchGets := fake.counts(); gets != 1 || batchGets != 1 {
+		t.Errorf("запросов свойств таблицы %d, чтений %d; ожидалось по одному", gets, batchGets)
+	}
+	// По два диапазона (результаты и настройки) на каждую вкладку с тестом, служебные вкладки не читаются
+	if len(fake.ranges) != 4 {
+		t.Errorf("прочитаны диапазоны %q", fake.ranges)
+	}
+
+	var got []string
+	for _, row := range fake.values["Leaderboard!A2:D"] {
+		got = append(got, fmt.Sprint(row))
+	}
+	// В «Тест» засчитывается последняя попытка (1 балл), в «Тест 2» — лучшая
+	want := []string{"[200 student 4 2]", "[300 other 1 1]"}
+	if strings.Join(got, ";") != strings.Join(want, ";") {
+		t.Errorf("Leaderboard = %q, ожидалось %q", got, want)
+	}
+}
+
+func TestSheetsUpdateLeaderboardReturnsClearError(t *testing.T) {
+	fake := newFakeSheets(leaderboardSheets())
+	fake.failClears = 1
+	repo := newFakeSheetsRepository(t, fake)
+
+	if err := repo.UpdateLeaderboard(context.Background()); err == nil {
+		t.Fatal("ошибка очистки Leaderboard не возвращена")
+	}
+	if _, ok := fake.values["Leaderboard!A2:D"]; ok {
+		t.Error("Leaderboard записан, хотя очистить его не удалось")
+	}
+}
+
+func historySheets() map[string][][]interface{} {
+	return map[string][][]interface{}{
+		"Attempts": {
+			{"a1", "2026-10-01 12:00:00", 200, "student", "Тест", 1, "1m0s", "", 2, 50, "нет"},
+			{"a2", "2026-10-01 13:00:00", 300, "other", "Тест", 2, "1m0s", "", 2, 100, "да"},
+		},
+		"Answers": {
+			{"a1", "1", "2+2?", "4", 1, "", 1},
+			{"a1", "2", "Столица Франции?", "Лондон", 0, "", 1},
+			{"a2", "1", "2+2?", "4", 1, "", 1},
+		},
+	}
+}
+
+func TestSheetsAttemptsReadsHistoryOnce(t *testing.T) {
+	fake := newFakeSheets(historySheets())
+	repo := newFakeSheetsRepository(t, fake)
+	ctx := context.Background()
+
+	for range 3 {
+		attempts, err := repo.Attempts(ctx, AttemptQuery{UserID: 200})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(attempts) != 1 || attempts[0].ID != "a1" || len(attempts[0].Answers) != 2 {
+			t.Fatalf("попытки = %+v", attempts)
+		}
+	}
+	if _, batchGets := fake.counts(); batchGets != 1 {
+		t.Errorf("история прочитана %d раз, ожидалось 1", batchGets)
+	}
+
+	// Записанная ботом попытка видна сразу, без повторного чтения вкладок
+	attempt := Attempt{
+		ID: "a3", TestName: "Тест", UserID: 200, Username: "student", Score: 2, MaxScore: 2, Passed: true,
+		FinishedAt: time.Date(2026, 10, 2, 9, 0, 0, 0, time.Local),
+		Answers:    []AttemptAnswer{{QuestionID: "1", Question: "2+2?", Answer: "4", Points: 1, MaxPoints: 1}},
+	}
+	if err := repo.SaveAttempt(ctx, attempt); err != nil {
+		t.Fatal(err)
+	}
+	attempts, err := repo.Attempts(ctx, AttemptQuery{UserID: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 || attempts[0].ID != "a3" {
+		t.Fatalf("после записи попытки = %+v", attempts)
+	}
+	if _, batchGets := fake.counts(); batchGets != 1 {
+		t.Errorf("после записи история перечитана: чтений %d", batchGets)
+	}
+	if rows := len(fake.values["Attempts"]); rows != 3 {
+		t.Errorf("во вкладке Attempts %d строк, ожидалось 3", rows)
+	}
+
+	// Устаревшая история читается заново
+	repo.historyLoadedAt = repo.historyLoadedAt.Add(-historyCacheTTL)
+	attempts, err = repo.Attempts(ctx, AttemptQuery{UserID: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, batchGets := fake.counts(); batchGets != 2 || len(attempts) != 2 {
+		t.Errorf("после истечения кэша: чтений %d, попыток %d", batchGets, len(attempts))
+	}
+}
+
+func TestSheetsAttemptsDoesNotCacheErrors(t *testing.T) {
+	fake := newFakeSheets(historySheets())
+	fake.failBatchGets = 1
+	repo := newFakeSheetsRepository(t, fake)
+	ctx := context.Background()
+
+	if _, err := repo.Attempts(ctx, AttemptQuery{UserID: 200}); err == nil {
+		t.Fatal("ошибка чтения истории не возвращена")
+	}
+	attempts, err := repo.Attempts(ctx, AttemptQuery{UserID: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 1 {
+		t.Errorf("после сбоя попытки = %+v", attempts)
+	}
+}
+
+func TestSheetsAttemptsWithoutHistorySheets(t *testing.T) {
+	fake := newFakeSheets(map[string][][]interface{}{"Тест": nil})
+	repo := newFakeSheetsRepository(t, fake)
+
+	attempts, err := repo.Attempts(context.Background(), AttemptQuery{})
+	if err != nil || len(attempts) != 0 {
+		t.Fatalf("Attempts без вкладок истории = %+v, %v", attempts, err)
+	}
+	if _, batchGets := fake.counts(); batchGets != 0 {
+		t.Errorf("без вкладок истории прочитано значений: %d", batchGets)
+	}
+}
+
+func TestDecodeResult(t *testing.T) {
+	results, err := parseA1Range("H2:Q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished := time.Date(2026, 10, 1, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		row      []interface{}
+		want     TestResult
+		wantCell string
+	}{
+		{
+			name: "схема 2",
+			row:  []interface{}{200.0, "student", 2.5, 4.0, 62.5, "нет", "2026-10-01 12:00:00", "1m30s", 3.0, 2.0},
+			want: TestResult{UserID: 200, Username: "student", Score: 2.5, MaxScore: 4, FinishedAt: finished, Duration: 90 * time.Second, Attempt: 3},
+		},
+		{
+			name: "схема 2: пустой зачет",
+			row:  []interface{}{200.0, "student", 4.0, 4.0, 100.0, "", "2026-10-01 12:00:00", "", "", 2.0},
+			want: TestResult{UserID: 200, Username: "student", Score: 4, MaxScore: 4, Passed: true, FinishedAt: finished},
+		},
+		{
+			name: "старая раскладка",
+			row:  []interface{}{200.0, "student", "3/5", "2026-10-01 12:00:00", "2m0s"},
+			want: TestResult{UserID: 200, Username: "student", Score: 3, MaxScore: 5, Passed: true, FinishedAt: finished, Duration: 2 * time.Minute},
+		},
+		{
+			name: "старая раскладка: балл стал датой",
+			row:  []interface{}{200.0, "student", dateSerial(2026, time.March, 5), "2026-10-01 12:00:00"},
+			want: TestResult{UserID: 200, Username: "student", Score: 3, MaxScore: 5, Passed: true, FinishedAt: finished},
+		},
+		{name: "старая раскладка: балл не разобрать", row: []interface{}{200.0, "student", "три"}, wantCell: "J2"},
+		{name: "схема 2: нет баллов", row: []interface{}{200.0, "student", "", 4.0, 0.0, "нет", "", "", 1.0, 2.0}, wantCell: "J2"},
+		{name: "схема 2: неверная попытка", row: []interface{}{200.0, "student", 1.0, 4.0, 25.0, "нет", "", "", "первая", 2.0}, wantCell: "P2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := sheetTable{Sheet: "Тест", Range: results, Rows: [][]interface{}{tt.row}}.decoder(0)
+			got, ok := decodeResult(d)
+			if tt.wantCell != "" {
+				var cellErr *CellError
+				if ok || len(d.Errors()) == 0 || !errors.As(d.Errors()[0], &cellErr) || cellErr.Cell != tt.wantCell {
+					t.Fatalf("decodeResult = %+v, %v; ошибки %v, ожидалась ошибка в ячейке %s", got, ok, d.Errors(), tt.wantCell)
+				}
+				return
+			}
+			tt.want.TestName = "Тест"
+			if !ok || got != tt.want {
+				t.Errorf("decodeResult = %+v, %v (ошибки %v)\nожидалось %+v", got, ok, d.Errors(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSheetsSaveResultReturnsReadError(t *testing.T) {
+	fake := newFakeSheets(map[string][][]interface{}{
+		"Тест!H2:Q": {{200, "student", 1, 2, 50, "да", "2026-10-01 12:00:00", "1m0s", 1, 2}},
+	})
+	fake.failBatchGets = 1
+	repo := newFakeSheetsRepository(t, fake)
+
+	result := TestResult{TestName: "Тест", UserID: 200, Username: "student", Score: 2, MaxScore: 2, Passed: true, FinishedAt: time.Now()}
+	if err := repo.SaveResult(context.Background(), result, CountBest); err == nil {
+		t.Fatal("ошибка чтения результатов не возвращена")
+	}
+	// Без прочитанных результатов строку пользователя не найти: новая строка задвоила бы результат
+	if rows := fake.values["Тест"]; len(rows) != 0 {
+		t.Errorf("добавлены строки %v", rows)
+	}
+}
+
+func TestSheetsTestCatalogReadsTitlesOnce(t *testing.T) {
+	fake := newFakeSheets(map[string][][]interface{}{
+		"Tests":    {{"Тест", "Первый тест", "", "", 1, "да"}},
+		"Тест":     nil,
+		"Черновик": nil,
+		"Attempts": nil,
+	})
+	repo := newFakeSheetsRepository(t, fake)
+
+	catalog, err := repo.TestCatalog(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gets, batchGets := fake.counts(); gets != 1 || batchGets != 1 {
+		t.Errorf("запросов свойств таблицы %d, чтений %d; ожидалось по одному", gets, batchGets)
+	}
+	if len(catalog.Tests) != 2 || len(catalog.Problems) != 0 {
+		t.Fatalf("каталог = %+v", catalog)
+	}
+	if info, ok := catalog.Find("Тест"); !ok || !info.Visible || info.DisplayName() != "Первый тест" {
+		t.Errorf("описание теста = %+v", info)
+	}
+	if info, ok := catalog.Find("Черновик"); !ok || info.Visible {
+		t.Errorf("неописанная вкладка должна быть черновиком: %+v", info)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sort"
+)
+
+// prepareAttempt выбирает вопросы для одной попытки по настройкам теста: случайные
+// QuestionCount вопросов и перемешивание вопросов и вариантов. Возвращает вопросы в порядке,
+// в котором их увидит пользователь, и для каждого вопроса порядок показа вариантов —
+// номера исходных вариантов (с единицы). Порядок вариантов nil, если они не перемешиваются.
+func prepareAttempt(test Test) ([]TestQuestion, [][]int) {
+	settings := test.Settings
+
+	indexes := make([]int, len(test.Questions))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	if settings.ShuffleQuestions || (settings.QuestionCount > 0 && settings.QuestionCount < len(indexes)) {
+		rand.Shuffle(len(indexes), func(i, j int) {
+			indexes[i], indexes[j] = indexes[j], indexes[i]
+		})
+	}
+	if settings.QuestionCount > 0 && settings.QuestionCount < len(indexes) {
+		indexes = indexes[:settings.QuestionCount]
+	}
+	if !settings.ShuffleQuestions {
+		sort.Ints(indexes)
+	}
+
+	questions := make([]TestQuestion, len(indexes))
+	for i, index := range indexes {
+		questions[i] = test.Questions[index]
+	}
+	if !settings.ShuffleOptions {
+		return questions, nil
+	}
+
+	optionOrder := make([][]int, len(questions))
+	for i, question := range questions {
+		order := make([]int, len(question.Options))
+		for j := range order {
+			order[j] = j + 1
+		}
+		rand.Shuffle(len(order), func(a, b int) {
+			order[a], order[b] = order[b], order[a]
+		})
+		optionOrder[i] = order
+	}
+	return questions, optionOrder
+}
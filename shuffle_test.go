@@ -0,0 +1,101 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func newShuffleTest(settings TestSettings) Test {
+	test := Test{Settings: settings}
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		test.Questions = append(test.Questions, TestQuestion{ID: id, Options: []string{"а", "б", "в", "г"}, CorrectAnswers: []int{1}})
+	}
+	return test
+}
+
+func TestPrepareAttempt(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  TestSettings
+		wantCount int
+		// wantSorted — вопросы идут в порядке таблицы
+		wantSorted bool
+	}{
+		{name: "без настроек", wantCount: 5, wantSorted: true},
+		{name: "часть вопросов", settings: TestSettings{QuestionCount: 3}, wantCount: 3, wantSorted: true},
+		{name: "вопросов больше, чем в тесте", settings: TestSettings{QuestionCount: 10}, wantCount: 5, wantSorted: true},
+		{name: "перемешивание вопросов", settings: TestSettings{ShuffleQuestions: true}, wantCount: 5},
+		{name: "перемешивание вариантов", settings: TestSettings{ShuffleOptions: true}, wantCount: 5, wantSorted: true},
+		{name: "все вместе", settings: TestSettings{QuestionCount: 2, ShuffleQuestions: true, ShuffleOptions: true}, wantCount: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Порядок случайный, поэтому свойства проверяются на нескольких попытках
+			for range 20 {
+				questions, optionOrder := prepareAttempt(newShuffleTest(tt.settings))
+				if len(questions) != tt.wantCount {
+					t.Fatalf("вопросов %d, ожидалось %d", len(questions), tt.wantCount)
+				}
+				ids := make([]string, len(questions))
+				for i, question := range questions {
+					ids[i] = question.ID
+				}
+				if unique := slices.Compact(slices.Sorted(slices.Values(ids))); len(unique) != len(ids) {
+					t.Fatalf("вопросы повторяются: %v", ids)
+				}
+				if tt.wantSorted && !slices.IsSorted(ids) {
+					t.Fatalf("порядок вопросов изменен: %v", ids)
+				}
+
+				if !tt.settings.ShuffleOptions {
+					if optionOrder != nil {
+						t.Fatalf("варианты перемешаны без настройки: %v", optionOrder)
+					}
+					continue
+				}
+				if len(optionOrder) != len(questions) {
+					t.Fatalf("порядков вариантов %d, вопросов %d", len(optionOrder), len(questions))
+				}
+				for _, order := range optionOrder {
+					if !slices.Equal(slices.Sorted(slices.Values(order)), []int{1, 2, 3, 4}) {
+						t.Fatalf("порядок вариантов %v — не перестановка вариантов 1..4", order)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSessionOriginalOption(t *testing.T) {
+	questions := []TestQuestion{
+		{ID: "1", Options: []string{"а", "б", "в"}},
+		{ID: "2", Options: []string{"а", "б"}},
+	}
+	session := &Session{Questions: questions, OptionOrder: [][]int{{3, 1, 2}, nil}}
+	tests := []struct {
+		qIndex, displayed int
+		want              int
+		wantOK            bool
+	}{
+		{qIndex: 0, displayed: 1, want: 3, wantOK: true},
+		{qIndex: 0, displayed: 2, want: 1, wantOK: true},
+		{qIndex: 0, displayed: 3, want: 2, wantOK: true},
+		{qIndex: 0, displayed: 0},
+		{qIndex: 0, displayed: 4},
+		// Без перемешивания кнопка совпадает с исходным вариантом
+		{qIndex: 1, displayed: 2, want: 2, wantOK: true},
+		{qIndex: 1, displayed: 3},
+	}
+	for _, tt := range tests {
+		got, ok := session.OriginalOption(tt.qIndex, tt.displayed)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("OriginalOption(%d, %d) = %d, %v; ожидалось %d, %v", tt.qIndex, tt.displayed, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	// Сессии, сохраненные до перемешивания вариантов, не содержат OptionOrder
+	legacy := &Session{Questions: questions}
+	if got := legacy.OptionOrderFor(0); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("OptionOrderFor без OptionOrder = %v", got)
+	}
+}
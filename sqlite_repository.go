@@ -0,0 +1,685 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations применяются по порядку; номер последней примененной хранится в PRAGMA user_version.
+// Таблицы повторяют раскладку Google-таблицы: questions — строки A:F вкладки теста,
+// tests.settings — диапазон настроек S:T, results — колонки H:Q, leaderboard — вкладка Leaderboard, teacher — вкладка Teacher,
+// attempts и attempt_answers — вкладки Attempts и Answers, catalog — вкладка Tests.
+var sqliteMigrations = []string{
+	`CREATE TABLE tests (
+		name     TEXT PRIMARY KEY,
+		position INTEGER NOT NULL
+	);
+	CREATE TABLE questions (
+		test_name TEXT NOT NULL REFERENCES tests(name) ON DELETE CASCADE,
+		row_num   INTEGER NOT NULL,
+		cells     TEXT NOT NULL,
+		PRIMARY KEY (test_name, row_num)
+	);
+	CREATE TABLE results (
+		test_name   TEXT NOT NULL,
+		user_id     INTEGER NOT NULL,
+		username    TEXT NOT NULL,
+		score       INTEGER NOT NULL,
+		total       INTEGER NOT NULL,
+		finished_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (test_name, user_id)
+	);
+	CREATE TABLE leaderboard (
+		user_id      INTEGER PRIMARY KEY,
+		username     TEXT NOT NULL,
+		total_score  INTEGER NOT NULL,
+		total_passed INTEGER NOT NULL
+	);
+	CREATE TABLE teacher (
+		id          INTEGER PRIMARY KEY CHECK (id = 1),
+		name        TEXT NOT NULL,
+		description TEXT NOT NULL,
+		contacts    TEXT NOT NULL,
+		photo_url   TEXT NOT NULL,
+		audio_url   TEXT NOT NULL,
+		video_url   TEXT NOT NULL
+	);`,
+	// Настройки теста — строки диапазона настроек вкладки в JSON
+	`ALTER TABLE tests ADD COLUMN settings TEXT NOT NULL DEFAULT '[]';`,
+	// Время прохождения теста в секундах
+	`ALTER TABLE results ADD COLUMN duration INTEGER NOT NULL DEFAULT 0;`,
+	// История всех попыток (вкладка Attempts) и ответы на каждый вопрос в них (вкладка Answers)
+	`CREATE TABLE attempts (
+		id          TEXT PRIMARY KEY,
+		test_name   TEXT NOT NULL,
+		user_id     INTEGER NOT NULL,
+		username    TEXT NOT NULL,
+		score       REAL NOT NULL,
+		total       INTEGER NOT NULL,
+		finished_at TIMESTAMP NOT NULL,
+		duration    INTEGER NOT NULL
+	);
+	CREATE INDEX attempts_user ON attempts (user_id, finished_at);
+	CREATE TABLE attempt_answers (
+		attempt_id  TEXT NOT NULL REFERENCES attempts(id) ON DELETE CASCADE,
+		position    INTEGER NOT NULL,
+		question_id TEXT NOT NULL,
+		question    TEXT NOT NULL,
+		answer      TEXT NOT NULL,
+		points      REAL NOT NULL,
+		timed_out   INTEGER NOT NULL,
+		PRIMARY KEY (attempt_id, position)
+	);`,
+	// Прерванные попытки: брошенные, истекшие и замененные новым тестом
+	`ALTER TABLE attempts ADD COLUMN interrupted INTEGER NOT NULL DEFAULT 0;`,
+	// Зачет по проходному проценту и вес вопроса в ответах; в колонке total теперь хранится
+	// максимальный балл с учетом весов вопросов (без весов он равен числу вопросов)
+	`ALTER TABLE results ADD COLUMN passed INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE attempts ADD COLUMN passed INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE attempt_answers ADD COLUMN max_points REAL NOT NULL DEFAULT 1;`,
+	// Номер засчитанной попытки (схема результатов 2, как в колонках results_range)
+	`ALTER TABLE results ADD COLUMN attempt INTEGER NOT NULL DEFAULT 0;`,
+	// Описания тестов — строки вкладки Tests в JSON
+	`CREATE TABLE catalog (
+		row_num INTEGER PRIMARY KEY,
+		cells   TEXT NOT NULL
+	);`,
+}
+
+// SQLiteRepository хранит данные бота в локальной базе SQLite
+type SQLiteRepository struct {
+	db *sql.DB
+	// layout — раскладка колонок, в которой хранятся строки вопросов (как во вкладке теста);
+	// settingsRange — диапазон настроек. По ним ошибки в строках указывают адрес ячейки во вкладке.
+	layout        questionLayout
+	settingsRange a1Range
+	// testsSheet — название вкладки Tests для адресов ошибок в описаниях тестов
+	testsSheet string
+
+	// leaderboardMutex не дает двум пересчетам Leaderboard выполняться одновременно
+	leaderboardMutex sync.Mutex
+}
+
+// OpenSQLiteRepository открывает (или создает) базу по пути path и применяет миграции.
+// Строки вопросов и настроек разбираются по раскладке вкладки теста из cfg.
+func OpenSQLiteRepository(path string, cfg SheetsConfig) (*SQLiteRepository, error) {
+	layout, err := cfg.questionLayout()
+	if err != nil {
+		return nil, err
+	}
+	settingsRange, err := parseA1Range(cfg.SettingsRange)
+	if err != nil {
+		return nil, fmt.Errorf("sheets.settings_range: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть базу SQLite %s: %w", path, err)
+	}
+	// SQLite не поддерживает параллельную запись, поэтому держим одно соединение
+	db.SetMaxOpenConns(1)
+
+	repo := &SQLiteRepository{db: db, layout: layout, settingsRange: settingsRange, testsSheet: cfg.TestsSheet}
+	if err := repo.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return repo, nil
+}
+
+// Close закрывает базу
+func (r *SQLiteRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *SQLiteRepository) migrate() error {
+	var version int
+	if err := r.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("не удалось прочитать версию схемы SQLite: %w", err)
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return fmt.Errorf("не удалось начать миграцию SQLite: %w", err)
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("ошибка миграции SQLite %d: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("ошибка миграции SQLite %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("ошибка миграции SQLite %d: %w", i+1, err)
+		}
+		log.Printf("Применена миграция SQLite %d", i+1)
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) TestNames(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT name FROM tests ORDER BY position, name")
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список тестов: %w", err)
+	}
+	defer rows.Close()
+
+	var testTitles []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("не удалось получить список тестов: %w", err)
+		}
+		testTitles = append(testTitles, name)
+	}
+	return testTitles, rows.Err()
+}
+
+func (r *SQLiteRepository) TestCatalog(ctx context.Context) (TestCatalog, error) {
+	names, err := r.TestNames(ctx)
+	if err != nil {
+		return TestCatalog{}, err
+	}
+	rows, err := r.catalogRows(ctx)
+	if err != nil {
+		return TestCatalog{}, err
+	}
+	catalog := buildCatalog(names, sheetTable{Sheet: r.testsSheet, Range: catalogRange(), Rows: rows})
+	logCellErrors(catalog.Problems)
+	return catalog, nil
+}
+
+func (r *SQLiteRepository) LoadTest(ctx context.Context, testName string) (Test, error) {
+	rows, err := r.testRows(ctx, testName)
+	if err != nil {
+		return Test{}, err
+	}
+	if len(rows) == 0 {
+		return Test{}, fmt.Errorf("в тесте %s не найдено вопросов", testName)
+	}
+	settings, err := r.testSettings(ctx, testName)
+	if err != nil {
+		return Test{}, err
+	}
+	test := parseTest(testName,
+		sheetTable{Sheet: testName, Range: r.layout.Range, Rows: rows},
+		sheetTable{Sheet: testName, Range: r.settingsRange, Rows: settings},
+		r.layout)
+	logCellErrors(test.Problems)
+	return test, nil
+}
+
+func (r *SQLiteRepository) SaveResult(ctx context.Context, result TestResult, counting AttemptCounting) error {
+	// Как и в таблице, у пользователя одна строка результата в тесте; заменяется ли она, решает counting
+	onConflict := `DO UPDATE SET
+			username = excluded.username,
+			score = excluded.score,
+			total = excluded.total,
+			passed = excluded.passed,
+			finished_at = excluded.finished_at,
+			duration = excluded.duration,
+			attempt = excluded.attempt`
+	switch counting {
+	case CountFirst:
+		onConflict = "DO NOTHING"
+	case CountLatest, CountAverage:
+		// Результат заменяется всегда
+	default:
+		onConflict += " WHERE excluded.score > results.score"
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO results (test_name, user_id, username, score, total, passed, finished_at, duration, attempt)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (test_name, user_id) `+onConflict,
+		result.TestName, result.UserID, result.Username, result.Score, result.MaxScore, result.Passed, result.FinishedAt.UTC(), int64(result.Duration/time.Second), result.Attempt)
+	if err != nil {
+		return fmt.Errorf("ошибка записи результата теста %s: %w", result.TestName, err)
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) SaveAttempt(ctx context.Context, attempt Attempt) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка записи попытки %s: %w", attempt.ID, err)
+	}
+	defer tx.Rollback()
+
+	if err := insertAttempt(ctx, tx, attempt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertAttempt записывает попытку и ее ответы в транзакции tx
+func insertAttempt(ctx context.Context, tx *sql.Tx, attempt Attempt) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO attempts (id, test_name, user_id, username, score, total, passed, finished_at, duration, interrupted)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		attempt.ID, attempt.TestName, attempt.UserID, attempt.Username, attempt.Score, attempt.MaxScore, attempt.Passed,
+		attempt.FinishedAt.UTC(), int64(attempt.Duration/time.Second), attempt.Interrupted); err != nil {
+		return fmt.Errorf("ошибка записи попытки %s: %w", attempt.ID, err)
+	}
+	for i, answer := range attempt.Answers {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO attempt_answers (attempt_id, position, question_id, question, answer, points, max_points, timed_out)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			attempt.ID, i, answer.QuestionID, answer.Question, answer.Answer, answer.Points, answer.MaxPoints, answer.TimedOut); err != nil {
+			return fmt.Errorf("ошибка записи ответов попытки %s: %w", attempt.ID, err)
+		}
+	}
+	return nil
+}
+
+func (r *SQLiteRepository) Attempts(ctx context.Context, query AttemptQuery) ([]Attempt, error) {
+	sqlQuery := "SELECT id, test_name, user_id, username, score, total, passed, finished_at, duration, interrupted FROM attempts"
+	var conditions []string
+	var args []interface{}
+	switch {
+	case query.UserID != 0:
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, query.UserID)
+	case query.Username != "":
+		conditions = append(conditions, "username = ? COLLATE NOCASE")
+		args = append(args, strings.TrimPrefix(query.Username, "@"))
+	}
+	if query.TestName != "" {
+		conditions = append(conditions, "test_name = ?")
+		args = append(args, query.TestName)
+	}
+	if len(conditions) > 0 {
+		sqlQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sqlQuery += " ORDER BY finished_at DESC"
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения истории попыток: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []Attempt
+	for rows.Next() {
+		var attempt Attempt
+		var finishedAt time.Time
+		var seconds int64
+		if err := rows.Scan(&attempt.ID, &attempt.TestName, &attempt.UserID, &attempt.Username, &attempt.Score, &attempt.MaxScore, &attempt.Passed, &finishedAt, &seconds, &attempt.Interrupted); err != nil {
+			return nil, fmt.Errorf("ошибка чтения истории попыток: %w", err)
+		}
+		attempt.FinishedAt = finishedAt.Local()
+		attempt.Duration = time.Duration(seconds) * time.Second
+		attempts = append(attempts, attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения истории попыток: %w", err)
+	}
+	rows.Close()
+
+	for i := range attempts {
+		answers, err := r.attemptAnswers(ctx, attempts[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		attempts[i].Answers = answers
+	}
+	return attempts, nil
+}
+
+// attemptAnswers возвращает ответы попытки по порядку вопросов
+func (r *SQLiteRepository) attemptAnswers(ctx context.Context, attemptID string) ([]AttemptAnswer, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT question_id, question, answer, points, max_points, timed_out FROM attempt_answers
+		WHERE attempt_id = ? ORDER BY position`, attemptID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ответов попытки %s: %w", attemptID, err)
+	}
+	defer rows.Close()
+
+	var answers []AttemptAnswer
+	for rows.Next() {
+		var answer AttemptAnswer
+		if err := rows.Scan(&answer.QuestionID, &answer.Question, &answer.Answer, &answer.Points, &answer.MaxPoints, &answer.TimedOut); err != nil {
+			return nil, fmt.Errorf("ошибка чтения ответов попытки %s: %w", attemptID, err)
+		}
+		answers = append(answers, answer)
+	}
+	return answers, rows.Err()
+}
+
+func (r *SQLiteRepository) UpdateLeaderboard(ctx context.Context) error {
+	r.leaderboardMutex.Lock()
+	defer r.leaderboardMutex.Unlock()
+
+	results, err := r.queryResults(ctx, "")
+	if err != nil {
+		return err
+	}
+	counting, err := r.attemptCounting(ctx)
+	if err != nil {
+		return err
+	}
+	aggregatedStats := aggregateLeaderboard(results, counting)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка записи в Leaderboard: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM leaderboard"); err != nil {
+		return fmt.Errorf("ошибка очистки Leaderboard: %w", err)
+	}
+	for _, stat := range aggregatedStats {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO leaderboard (user_id, username, total_score, total_passed) VALUES (?, ?, ?, ?)",
+			stat.UserID, stat.Username, stat.TotalScore, stat.TotalPassed); err != nil {
+			return fmt.Errorf("ошибка записи в Leaderboard: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// attemptCounting возвращает правило засчитывания попыток каждого теста из его настроек
+func (r *SQLiteRepository) attemptCounting(ctx context.Context) (map[string]AttemptCounting, error) {
+	names, err := r.TestNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counting := make(map[string]AttemptCounting, len(names))
+	for _, name := range names {
+		settings, err := r.testSettings(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		parsed, _ := parseTestSettings(sheetTable{Sheet: name, Range: r.settingsRange, Rows: settings})
+		counting[name] = parsed.CountedAttempt
+	}
+	return counting, nil
+}
+
+func (r *SQLiteRepository) UserStats(ctx context.Context, userID int64) (UserStats, error) {
+	var stats UserStats
+	var id int64
+	err := r.db.QueryRowContext(ctx,
+		"SELECT user_id, username, total_score, total_passed FROM leaderboard WHERE user_id = ?", userID).
+		Scan(&id, &stats.Username, &stats.TotalScore, &stats.TotalPassed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, fmt.Errorf("ошибка чтения Leaderboard: %w", err)
+	}
+	stats.UserID = strconv.FormatInt(id, 10)
+	return stats, nil
+}
+
+func (r *SQLiteRepository) TeacherProfile(ctx context.Context) (TeacherProfile, error) {
+	var profile TeacherProfile
+	err := r.db.QueryRowContext(ctx,
+		"SELECT name, description, contacts, photo_url, audio_url, video_url FROM teacher WHERE id = 1").
+		Scan(&profile.Name, &profile.Description, &profile.Contacts, &profile.PhotoURL, &profile.AudioURL, &profile.VideoURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TeacherProfile{Name: "Не указано", Contacts: "Не указано"}, nil
+	}
+	if err != nil {
+		return profile, fmt.Errorf("ошибка чтения профиля преподавателя: %w", err)
+	}
+	return profile, nil
+}
+
+// --- ПОЛНАЯ ВЫГРУЗКА И ЗАГРУЗКА (для синхронизации с Google Sheets) ---
+
+func (r *SQLiteRepository) testRows(ctx context.Context, testName string) ([][]interface{}, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT cells FROM questions WHERE test_name = ? ORDER BY row_num", testName)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения вопросов теста %s: %w", testName, err)
+	}
+	defer rows.Close()
+
+	var values [][]interface{}
+	for rows.Next() {
+		var cells string
+		if err := rows.Scan(&cells); err != nil {
+			return nil, fmt.Errorf("ошибка чтения вопросов теста %s: %w", testName, err)
+		}
+		var row []interface{}
+		if err := json.Unmarshal([]byte(cells), &row); err != nil {
+			return nil, fmt.Errorf("поврежденная строка вопроса в тесте %s: %w", testName, err)
+		}
+		values = append(values, row)
+	}
+	return values, rows.Err()
+}
+
+func (r *SQLiteRepository) testSettings(ctx context.Context, testName string) ([][]interface{}, error) {
+	var cells string
+	err := r.db.QueryRowContext(ctx, "SELECT settings FROM tests WHERE name = ?", testName).Scan(&cells)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения настроек теста %s: %w", testName, err)
+	}
+	var rows [][]interface{}
+	if err := json.Unmarshal([]byte(cells), &rows); err != nil {
+		return nil, fmt.Errorf("поврежденные настройки теста %s: %w", testName, err)
+	}
+	return rows, nil
+}
+
+// catalogRows возвращает строки описаний тестов в том виде, как они лежат во вкладке Tests
+func (r *SQLiteRepository) catalogRows(ctx context.Context) ([][]interface{}, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT cells FROM catalog ORDER BY row_num")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения описаний тестов: %w", err)
+	}
+	defer rows.Close()
+
+	var catalog [][]interface{}
+	for rows.Next() {
+		var cells string
+		if err := rows.Scan(&cells); err != nil {
+			return nil, fmt.Errorf("ошибка чтения описаний тестов: %w", err)
+		}
+		var row []interface{}
+		if err := json.Unmarshal([]byte(cells), &row); err != nil {
+			return nil, fmt.Errorf("поврежденное описание теста: %w", err)
+		}
+		catalog = append(catalog, row)
+	}
+	return catalog, rows.Err()
+}
+
+func (r *SQLiteRepository) testResults(ctx context.Context, testName string) ([]TestResult, error) {
+	return r.queryResults(ctx, testName)
+}
+
+// queryResults возвращает результаты теста testName или всех тестов, если testName пустой
+func (r *SQLiteRepository) queryResults(ctx context.Context, testName string) ([]TestResult, error) {
+	query := "SELECT test_name, user_id, username, score, total, passed, finished_at, duration, attempt FROM results"
+	var args []interface{}
+	if testName != "" {
+		query += " WHERE test_name = ?"
+		args = append(args, testName)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения результатов: %w", err)
+	}
+	defer rows.Close()
+
+	var results []TestResult
+	for rows.Next() {
+		var result TestResult
+		var finishedAt time.Time
+		var seconds int64
+		if err := rows.Scan(&result.TestName, &result.UserID, &result.Username, &result.Score, &result.MaxScore, &result.Passed, &finishedAt, &seconds, &result.Attempt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения результатов: %w", err)
+		}
+		result.FinishedAt = finishedAt.Local()
+		result.Duration = time.Duration(seconds) * time.Second
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (r *SQLiteRepository) replaceTest(ctx context.Context, testName string, position int, rows, settings [][]interface{}, results []TestResult) error {
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить настройки теста %s: %w", testName, err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить тест %s: %w", testName, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO tests (name, position, settings) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET position = excluded.position, settings = excluded.settings`,
+		testName, position, string(settingsJSON)); err != nil {
+		return fmt.Errorf("не удалось сохранить тест %s: %w", testName, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM questions WHERE test_name = ?", testName); err != nil {
+		return fmt.Errorf("не удалось сохранить тест %s: %w", testName, err)
+	}
+	for i, row := range rows {
+		cells, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("не удалось сохранить тест %s: %w", testName, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO questions (test_name, row_num, cells) VALUES (?, ?, ?)", testName, i+2, string(cells)); err != nil {
+			return fmt.Errorf("не удалось сохранить тест %s: %w", testName, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM results WHERE test_name = ?", testName); err != nil {
+		return fmt.Errorf("не удалось сохранить результаты теста %s: %w", testName, err)
+	}
+	for _, result := range results {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO results (test_name, user_id, username, score, total, passed, finished_at, duration, attempt)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (test_name, user_id) DO UPDATE SET
+				username = excluded.username,
+				score = excluded.score,
+				total = excluded.total,
+				passed = excluded.passed,
+				finished_at = excluded.finished_at,
+				duration = excluded.duration,
+				attempt = excluded.attempt
+			WHERE excluded.score > results.score`,
+			testName, result.UserID, result.Username, result.Score, result.MaxScore, result.Passed, result.FinishedAt.UTC(), int64(result.Duration/time.Second), result.Attempt); err != nil {
+			return fmt.Errorf("не удалось сохранить результаты теста %s: %w", testName, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLiteRepository) replaceTeacherProfile(ctx context.Context, profile TeacherProfile) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO teacher (id, name, description, contacts, photo_url, audio_url, video_url)
+		VALUES (1, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			description = excluded.description,
+			contacts = excluded.contacts,
+			photo_url = excluded.photo_url,
+			audio_url = excluded.audio_url,
+			video_url = excluded.video_url`,
+		profile.Name, profile.Description, profile.Contacts, profile.PhotoURL, profile.AudioURL, profile.VideoURL)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить профиль преподавателя: %w", err)
+	}
+	return nil
+}
+
+// replaceCatalog заменяет описания тестов
+func (r *SQLiteRepository) replaceCatalog(ctx context.Context, rows [][]interface{}) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить описания тестов: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM catalog"); err != nil {
+		return fmt.Errorf("не удалось очистить описания тестов: %w", err)
+	}
+	for i, row := range rows {
+		cells, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("не удалось сохранить описания тестов: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO catalog (row_num, cells) VALUES (?, ?)", i+2, string(cells)); err != nil {
+			return fmt.Errorf("не удалось сохранить описания тестов: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// replaceAttempts заменяет всю историю попыток
+func (r *SQLiteRepository) replaceAttempts(ctx context.Context, attempts []Attempt) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("не удалось сохранить историю попыток: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM attempts"); err != nil {
+		return fmt.Errorf("не удалось очистить историю попыток: %w", err)
+	}
+	for _, attempt := range attempts {
+		if err := insertAttempt(ctx, tx, attempt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// pruneTests удаляет из базы тесты, которых нет в списке keep, вместе с их результатами
+func (r *SQLiteRepository) pruneTests(ctx context.Context, keep []string) error {
+	existing, err := r.TestNames(ctx)
+	if err != nil {
+		return err
+	}
+
+	keepSet := make(map[string]bool, len(keep))
+	for _, name := range keep {
+		keepSet[name] = true
+	}
+
+	for _, name := range existing {
+		if keepSet[name] {
+			continue
+		}
+		if _, err := r.db.ExecContext(ctx, "DELETE FROM tests WHERE name = ?", name); err != nil {
+			return fmt.Errorf("не удалось удалить тест %s: %w", name, err)
+		}
+		if _, err := r.db.ExecContext(ctx, "DELETE FROM results WHERE test_name = ?", name); err != nil {
+			return fmt.Errorf("не удалось удалить результаты теста %s: %w", name, err)
+		}
+		log.Printf("Тест %s удален из базы", name)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// syncStore — хранилище, которое умеет целиком отдавать и перезаписывать данные тестов.
+// Через него вкладки Google-таблицы переносятся в SQLite и обратно.
+type syncStore interface {
+	Repository
+	testRows(ctx context.Context, testName string) ([][]interface{}, error)
+	testSettings(ctx context.Context, testName string) ([][]interface{}, error)
+	testResults(ctx context.Context, testName string) ([]TestResult, error)
+	replaceTest(ctx context.Context, testName string, position int, rows, settings [][]interface{}, results []TestResult) error
+	catalogRows(ctx context.Context) ([][]interface{}, error)
+	replaceTeacherProfile(ctx context.Context, profile TeacherProfile) error
+	replaceCatalog(ctx context.Context, rows [][]interface{}) error
+	replaceAttempts(ctx context.Context, attempts []Attempt) error
+}
+
+// runSync выполняет команду синхронизации:
+//
+//	sync import [класс] — загрузить тесты с описаниями, результаты, историю попыток и профиль преподавателя из Google Sheets в SQLite
+//	sync export [класс] — выгрузить их из SQLite обратно в Google Sheets
+//
+// Без указания класса синхронизируются все классы из настроек.
+func runSync(ctx context.Context, cfg Config, args []string) error {
+	if len(args) < 1 || len(args) > 2 || (args[0] != "import" && args[0] != "export") {
+		return fmt.Errorf("использование: sync import|export [класс]")
+	}
+
+	found := false
+	for _, tenant := range cfg.TenantConfigs() {
+		if len(args) == 2 && tenant.ID != args[1] {
+			continue
+		}
+		found = true
+		log.Printf("Синхронизация класса %s", tenant.ID)
+		if err := syncTenant(ctx, cfg.ForTenant(tenant), args[0]); err != nil {
+			return fmt.Errorf("класс %s: %w", tenant.ID, err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("класс %q не найден в настройках", args[1])
+	}
+	return nil
+}
+
+// syncTenant переносит данные одного класса в направлении direction (import или export)
+func syncTenant(ctx context.Context, cfg Config, direction string) error {
+	if err := cfg.Sheets.Validate(); err != nil {
+		return err
+	}
+
+	service, err := newSheetsService(ctx, cfg.Sheets.CredentialsFile)
+	if err != nil {
+		return err
+	}
+	sheetsRepo, err := NewSheetsRepository(service, cfg.Sheets)
+	if err != nil {
+		return err
+	}
+
+	sqliteRepo, err := OpenSQLiteRepository(cfg.Storage.SQLitePath, cfg.Sheets)
+	if err != nil {
+		return err
+	}
+	defer sqliteRepo.Close()
+
+	if direction == "import" {
+		names, err := syncRepositories(ctx, sheetsRepo, sqliteRepo)
+		if err != nil {
+			return err
+		}
+		// Тесты, удаленные из таблицы, удаляем и из базы
+		return sqliteRepo.pruneTests(ctx, names)
+	}
+	_, err = syncRepositories(ctx, sqliteRepo, sheetsRepo)
+	return err
+}
+
+// syncRepositories переносит все тесты с описаниями и результатами, историю попыток и профиль преподавателя из from в to,
+// после чего пересчитывает Leaderboard в to. Возвращает названия перенесенных тестов.
+func syncRepositories(ctx context.Context, from, to syncStore) ([]string, error) {
+	names, err := from.TestNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, name := range names {
+		rows, err := from.testRows(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		settings, err := from.testSettings(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		results, err := from.testResults(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := to.replaceTest(ctx, name, i, rows, settings, results); err != nil {
+			return nil, err
+		}
+		log.Printf("Тест %s перенесен: %d вопросов, %d результатов", name, len(rows), len(results))
+	}
+
+	catalog, err := from.catalogRows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := to.replaceCatalog(ctx, catalog); err != nil {
+		return nil, err
+	}
+	log.Printf("Описания тестов перенесены: %d строк", len(catalog))
+
+	attempts, err := from.Attempts(ctx, AttemptQuery{})
+	if err != nil {
+		return nil, err
+	}
+	if err := to.replaceAttempts(ctx, attempts); err != nil {
+		return nil, err
+	}
+	log.Printf("История попыток перенесена: %d попыток", len(attempts))
+
+	profile, err := from.TeacherProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := to.replaceTeacherProfile(ctx, profile); err != nil {
+		return nil, err
+	}
+
+	if err := to.UpdateLeaderboard(ctx); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownInviteCode возвращается, если код из /join не совпадает ни с одним классом
+var ErrUnknownInviteCode = errors.New("неизвестный код класса")
+
+// Tenant — класс (группа) со своим банком вопросов, Leaderboard и профилем преподавателя
+type Tenant struct {
+	ID         string
+	Name       string
+	InviteCode string
+	Repo       Repository
+	// Admins — преподаватели класса и общие администраторы бота
+	Admins map[int64]bool
+
+	close func()
+}
+
+// DisplayName возвращает название класса для сообщений пользователю
+func (t *Tenant) DisplayName() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.ID
+}
+
+// TenantRegistry определяет, к какому классу относится пользователь.
+// Порядок: класс, за которым закреплен чат; класс, к которому ученик присоединился через /join;
+// единственный класс, если он один.
+type TenantRegistry struct {
+	list   []*Tenant
+	byID   map[string]*Tenant
+	byChat map[int64]*Tenant
+	byCode map[string]*Tenant
+
+	mu           sync.RWMutex
+	bindings     map[int64]string
+	bindingsFile string
+}
+
+// openTenants открывает хранилища всех классов из настроек и загружает привязки учеников
+func openTenants(ctx context.Context, cfg Config) (*TenantRegistry, error) {
+	registry := &TenantRegistry{
+		byID:         make(map[string]*Tenant),
+		byChat:       make(map[int64]*Tenant),
+		byCode:       make(map[string]*Tenant),
+		bindings:     make(map[int64]string),
+		bindingsFile: cfg.TenantBindingsFile,
+	}
+
+	for _, tc := range cfg.TenantConfigs() {
+		repo, closeRepo, err := openRepository(ctx, cfg.ForTenant(tc))
+		if err != nil {
+			registry.Close()
+			return nil, fmt.Errorf("класс %s: %w", tc.ID, err)
+		}
+
+		tenant := &Tenant{ID: tc.ID, Name: tc.Name, InviteCode: tc.InviteCode, Repo: repo, Admins: make(map[int64]bool), close: closeRepo}
+		for _, userID := range append(append([]int64(nil), cfg.Admins...), tc.Admins...) {
+			tenant.Admins[userID] = true
+		}
+		registry.list = append(registry.list, tenant)
+		registry.byID[tc.ID] = tenant
+		for _, chatID := range tc.Chats {
+			registry.byChat[chatID] = tenant
+		}
+		if tc.InviteCode != "" {
+			registry.byCode[strings.ToLower(tc.InviteCode)] = tenant
+		}
+	}
+
+	if err := registry.loadBindings(); err != nil {
+		registry.Close()
+		return nil, err
+	}
+	log.Printf("Загружено классов: %d", len(registry.list))
+	return registry, nil
+}
+
+// All возвращает все классы
+func (r *TenantRegistry) All() []*Tenant {
+	return r.list
+}
+
+// Get возвращает класс по ID. Пустой ID (сессии, сохраненные до появления классов)
+// соответствует единственному классу, если он один.
+func (r *TenantRegistry) Get(id string) (*Tenant, bool) {
+	if id == "" && len(r.list) == 1 {
+		return r.list[0], true
+	}
+	tenant, ok := r.byID[id]
+	return tenant, ok
+}
+
+// Resolve определяет класс пользователя userID в чате chatID
+func (r *TenantRegistry) Resolve(chatID, userID int64) (*Tenant, bool) {
+	if tenant, ok := r.byChat[chatID]; ok {
+		return tenant, true
+	}
+
+	r.mu.RLock()
+	id, ok := r.bindings[userID]
+	r.mu.RUnlock()
+	if ok {
+		if tenant, ok := r.byID[id]; ok {
+			return tenant, true
+		}
+	}
+
+	if len(r.list) == 1 {
+		return r.list[0], true
+	}
+	return nil, false
+}
+
+// IsAdmin сообщает, что пользователь — администратор хотя бы одного класса
+func (r *TenantRegistry) IsAdmin(userID int64) bool {
+	for _, tenant := range r.list {
+		if tenant.Admins[userID] {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminTenant определяет класс, которым управляет администратор userID: класс чата (или ученика),
+// если администратор в нем, иначе первый класс, где он администратор
+func (r *TenantRegistry) AdminTenant(chatID, userID int64) (*Tenant, bool) {
+	if tenant, ok := r.Resolve(chatID, userID); ok && tenant.Admins[userID] {
+		return tenant, true
+	}
+	for _, tenant := range r.list {
+		if tenant.Admins[userID] {
+			return tenant, true
+		}
+	}
+	return nil, false
+}
+
+// Join привязывает ученика к классу с кодом code
+func (r *TenantRegistry) Join(userID int64, code string) (*Tenant, error) {
+	tenant, ok := r.byCode[strings.ToLower(strings.TrimSpace(code))]
+	if !ok {
+		return nil, ErrUnknownInviteCode
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, hadPrevious := r.bindings[userID]
+	r.bindings[userID] = tenant.ID
+	if err := r.saveBindings(); err != nil {
+		// Привязка в памяти не должна расходиться с файлом: после перезапуска ученик оказался бы в прежнем классе
+		if hadPrevious {
+			r.bindings[userID] = previous
+		} else {
+			delete(r.bindings, userID)
+		}
+		return nil, err
+	}
+	return tenant, nil
+}
+
+// Close закрывает хранилища всех классов
+func (r *TenantRegistry) Close() {
+	for _, tenant := range r.list {
+		tenant.close()
+	}
+}
+
+func (r *TenantRegistry) loadBindings() error {
+	if r.bindingsFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(r.bindingsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать файл привязок к классам %s: %w", r.bindingsFile, err)
+	}
+	if err := json.Unmarshal(data, &r.bindings); err != nil {
+		return fmt.Errorf("не удалось разобрать файл привязок к классам %s: %w", r.bindingsFile, err)
+	}
+	return nil
+}
+
+// saveBindings сохраняет привязки на диск; вызывающий должен держать r.mu
+func (r *TenantRegistry) saveBindings() error {
+	if r.bindingsFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(r.bindings)
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать привязки к классам: %w", err)
+	}
+	tmp := r.bindingsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("не удалось записать файл привязок к классам: %w", err)
+	}
+	if err := os.Rename(tmp, r.bindingsFile); err != nil {
+		return fmt.Errorf("не удалось сохранить файл привязок к классам %s: %w", r.bindingsFile, err)
+	}
+	return nil
+}
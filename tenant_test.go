@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestRegistry создает реестр из двух классов с кодами "7A" и "7b"; привязки хранятся в bindingsFile
+func newTestRegistry(bindingsFile string) *TenantRegistry {
+	a := &Tenant{ID: "7a", InviteCode: "7A"}
+	b := &Tenant{ID: "7b", InviteCode: "7b"}
+	return &TenantRegistry{
+		list:         []*Tenant{a, b},
+		byID:         map[string]*Tenant{a.ID: a, b.ID: b},
+		byChat:       map[int64]*Tenant{},
+		byCode:       map[string]*Tenant{"7a": a, "7b": b},
+		bindings:     map[int64]string{},
+		bindingsFile: bindingsFile,
+	}
+}
+
+func TestTenantRegistryJoin(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		want    string
+		wantErr error
+	}{
+		{name: "код без учета регистра", code: "7a", want: "7a"},
+		{name: "пробелы вокруг кода", code: "  7B ", want: "7b"},
+		{name: "неизвестный код", code: "8a", wantErr: ErrUnknownInviteCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bindingsFile := filepath.Join(t.TempDir(), "bindings.json")
+			registry := newTestRegistry(bindingsFile)
+
+			tenant, err := registry.Join(1, tt.code)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Join(%q): ошибка %v, ожидалась %v", tt.code, err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(registry.bindings) != 0 {
+					t.Errorf("после ошибки привязки = %v", registry.bindings)
+				}
+				return
+			}
+			if tenant.ID != tt.want {
+				t.Errorf("Join(%q) = %s, ожидался %s", tt.code, tenant.ID, tt.want)
+			}
+
+			// Привязка сохраняется в файл и переживает перезапуск
+			reloaded := newTestRegistry(bindingsFile)
+			if err := reloaded.loadBindings(); err != nil {
+				t.Fatal(err)
+			}
+			if got, ok := reloaded.Resolve(0, 1); !ok || got.ID != tt.want {
+				t.Errorf("после перезапуска класс = %v, ожидался %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantRegistryJoinKeepsBindingOnSaveError(t *testing.T) {
+	// Файл привязок нельзя записать: каталога, в котором он лежит, нет
+	registry := newTestRegistry(filepath.Join(t.TempDir(), "missing", "bindings.json"))
+	registry.bindings[1] = "7a"
+
+	if _, err := registry.Join(1, "7b"); err == nil {
+		t.Fatal("ошибка записи привязок не возвращена")
+	}
+	if got := registry.bindings[1]; got != "7a" {
+		t.Errorf("после ошибки ученик привязан к %q, ожидался прежний класс 7a", got)
+	}
+
+	if _, err := registry.Join(2, "7b"); err == nil {
+		t.Fatal("ошибка записи привязок не возвращена")
+	}
+	if _, ok := registry.bindings[2]; ok {
+		t.Error("после ошибки осталась новая привязка")
+	}
+	if _, err := os.Stat(registry.bindingsFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("файл привязок: %v", err)
+	}
+}
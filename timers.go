@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// scheduleTimeout запускает таймер вопроса qIndex сессии key. Когда время выйдет, обработка
+// ставится в очередь чата через dispatcher, поэтому не пересекается с ответами пользователя.
+func (a *app) scheduleTimeout(key SessionKey, qIndex int, deadline time.Time) {
+	a.timersMu.Lock()
+	defer a.timersMu.Unlock()
+
+	if a.timers == nil {
+		a.timers = make(map[SessionKey]*time.Timer)
+	}
+	if timer, ok := a.timers[key]; ok {
+		timer.Stop()
+	}
+	a.timers[key] = time.AfterFunc(time.Until(deadline), func() {
+		a.dispatcher.Submit(key.ChatID, func(ctx context.Context) {
+			if err := a.handleQuestionTimeout(ctx, key, qIndex); err != nil {
+				log.Printf("Ошибка при истечении времени на вопрос: %v", err)
+			}
+		})
+	})
+}
+
+// cancelTimeout останавливает таймер сессии key
+func (a *app) cancelTimeout(key SessionKey) {
+	a.timersMu.Lock()
+	defer a.timersMu.Unlock()
+
+	if timer, ok := a.timers[key]; ok {
+		timer.Stop()
+		delete(a.timers, key)
+	}
+}
+
+// handleQuestionTimeout засчитывает вопрос qIndex как неотвеченный, если пользователь
+// не успел ответить, и задает следующий вопрос
+func (a *app) handleQuestionTimeout(ctx context.Context, key SessionKey, qIndex int) error {
+	var testTimeUp bool
+	var messageID int
+	session, err := a.sessions.Update(key, func(s *Session) error {
+		now := time.Now()
+		if s.Position != qIndex || !s.QuestionTimeUp(now) {
+			return errStaleQuestion
+		}
+		messageID = s.QuestionMessageID
+		testTimeUp = s.TimeOut(now)
+		return nil
+	})
+	if errors.Is(err, ErrSessionNotFound) || errors.Is(err, errStaleQuestion) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось обновить сессию %s: %w", key, err)
+	}
+
+	log.Printf("Пользователь [%s] не успел ответить на вопрос %d теста %s", session.Username, qIndex+1, session.TestName)
+	return a.questionTimedOut(ctx, session, qIndex, messageID, testTimeUp)
+}
+
+// questionTimedOut сообщает в сообщении с вопросом, что время вышло, и задает следующий вопрос
+// (или завершает тест, если вышло время на весь тест)
+func (a *app) questionTimedOut(ctx context.Context, session *Session, qIndex, messageID int, testTimeUp bool) error {
+	text := fmt.Sprintf("⏰ Время на вопрос %d вышло, ответ не засчитан.", qIndex+1)
+	if testTimeUp {
+		text = "⏰ Время на тест вышло. Оставшиеся вопросы не засчитаны."
+	} else if feedback, ok := instantFeedback(session, qIndex); ok {
+		text = feedback
+	}
+
+	if messageID != 0 {
+		if err := a.editQuestionMessage(session.ChatID, messageID, text); err != nil {
+			a.bot.Send(tgbotapi.NewMessage(session.ChatID, text))
+		}
+	} else {
+		a.bot.Send(tgbotapi.NewMessage(session.ChatID, text))
+	}
+
+	return a.sendQuestion(ctx, session)
+}
+
+// lateAnswer запоминает, что ответ пришел после истечения времени (например, если таймер
+// потерялся при перезапуске бота). Тогда вопрос засчитывается как неотвеченный.
+type lateAnswer struct {
+	timedOut   bool
+	testTimeUp bool
+	messageID  int
+}
+
+// check вызывается внутри SessionStore.Update: если время на текущий вопрос вышло,
+// пропускает его и возвращает true
+func (l *lateAnswer) check(s *Session, now time.Time) bool {
+	if !s.QuestionTimeUp(now) {
+		return false
+	}
+	l.timedOut = true
+	l.messageID = s.QuestionMessageID
+	l.testTimeUp = s.TimeOut(now)
+	return true
+}
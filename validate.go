@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// TestReport — результат проверки одного теста
+type TestReport struct {
+	TestName string
+	// Questions — сколько вопросов теста загружено без ошибок
+	Questions int
+	// Problems — ошибки в ячейках и ошибки загрузки вкладки
+	Problems []error
+	// Catalog — отчет об описаниях тестов во вкладке Tests, а не о самом тесте
+	Catalog bool
+	// Settings — настройки теста, как их понял бот
+	Settings TestSettings
+}
+
+// validateTests загружает тесты names (все тесты хранилища, если names пусто) и собирает ошибки
+// в вопросах и настройках: пропущенные варианты, неверные номера ответов, повторяющиеся ID,
+// пустые вопросы. При проверке всех тестов проверяются и описания во вкладке Tests.
+// Ошибка возвращается, только если не удалось получить список тестов.
+func validateTests(ctx context.Context, repo Repository, names ...string) ([]TestReport, error) {
+	var reports []TestReport
+	if len(names) == 0 {
+		catalog, err := repo.TestCatalog(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range catalog.Tests {
+			names = append(names, info.Name)
+		}
+		if len(catalog.Problems) > 0 {
+			reports = append(reports, TestReport{TestName: "Описания тестов", Problems: catalog.Problems, Catalog: true})
+		}
+	}
+
+	for _, name := range names {
+		test, err := repo.LoadTest(ctx, name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			reports = append(reports, TestReport{TestName: name, Problems: []error{err}})
+			continue
+		}
+		report := TestReport{TestName: name, Questions: len(test.Questions), Problems: test.Problems, Settings: test.Settings}
+		if len(test.Questions) == 0 {
+			report.Problems = append(report.Problems, errors.New("в тесте нет ни одного корректного вопроса"))
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// countProblems возвращает общее число ошибок в отчетах
+func countProblems(reports []TestReport) int {
+	count := 0
+	for _, report := range reports {
+		count += len(report.Problems)
+	}
+	return count
+}
+
+// validationReportText формирует отчет о проверке тестов класса className.
+// У ошибок в ячейках указывается только адрес ячейки: вкладка видна по заголовку теста.
+func validationReportText(className string, reports []TestReport) string {
+	var b strings.Builder
+	tests, failed := 0, 0
+	for _, report := range reports {
+		if report.Catalog {
+			continue
+		}
+		tests++
+		if len(report.Problems) > 0 {
+			failed++
+		}
+	}
+	fmt.Fprintf(&b, "🔎 Проверка тестов класса «%s»: тестов — %d, с ошибками — %d.", className, tests, failed)
+	if tests == 0 {
+		b.WriteString("\nТесты не найдены.")
+	}
+
+	for _, report := range reports {
+		switch {
+		case report.Catalog:
+			fmt.Fprintf(&b, "\n\n⚠️ %s: ошибок — %d", report.TestName, len(report.Problems))
+		case len(report.Problems) == 0:
+			fmt.Fprintf(&b, "\n\n✅ %s: вопросов — %d%s", report.TestName, report.Questions, scoringSummary(report.Settings))
+		default:
+			fmt.Fprintf(&b, "\n\n⚠️ %s: вопросов — %d%s, ошибок — %d", report.TestName, report.Questions, scoringSummary(report.Settings), len(report.Problems))
+		}
+		for _, problem := range report.Problems {
+			var cellErr *CellError
+			if errors.As(problem, &cellErr) && cellErr.Sheet == report.TestName {
+				fmt.Fprintf(&b, "\n• %s: %v", cellErr.Cell, cellErr.Err)
+			} else {
+				fmt.Fprintf(&b, "\n• %v", problem)
+			}
+		}
+	}
+	return b.String()
+}
+
+// scoringSummary перечисляет проходной процент и штраф так, как их понял бот: по отчету видно,
+// если ячейка с процентным форматом была прочитана не так, как задумывал преподаватель
+func scoringSummary(settings TestSettings) string {
+	summary := ""
+	if settings.PassPercent > 0 {
+		summary += fmt.Sprintf(", зачет от %s%%", formatPoints(settings.PassPercent))
+	}
+	if settings.WrongPenalty > 0 {
+		summary += fmt.Sprintf(", штраф %s веса вопроса", formatPoints(settings.WrongPenalty))
+	}
+	return summary
+}
+
+// runValidate проверяет тесты всех классов (или одного класса) и выводит отчет:
+//
+//	validate [-o отчет.txt] [класс]
+//
+// Если в тестах найдены ошибки, возвращает ошибку, чтобы команду можно было использовать в скриптах.
+func runValidate(ctx context.Context, cfg Config, args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	output := flags.String("o", "", "файл для отчета (по умолчанию отчет выводится в консоль)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("использование: validate [-o отчет.txt] [класс]")
+	}
+	if problems := cfg.validateTenants(); len(problems) > 0 {
+		return fmt.Errorf("некорректные настройки:\n- %s", strings.Join(problems, "\n- "))
+	}
+
+	var texts []string
+	found, total := false, 0
+	for _, tenant := range cfg.TenantConfigs() {
+		if flags.NArg() == 1 && tenant.ID != flags.Arg(0) {
+			continue
+		}
+		found = true
+		reports, err := validateTenantTests(ctx, cfg.ForTenant(tenant))
+		if err != nil {
+			return fmt.Errorf("класс %s: %w", tenant.ID, err)
+		}
+		className := tenant.Name
+		if className == "" {
+			className = tenant.ID
+		}
+		texts = append(texts, validationReportText(className, reports))
+		total += countProblems(reports)
+	}
+	if !found {
+		return fmt.Errorf("класс %q не найден в настройках", flags.Arg(0))
+	}
+
+	report := strings.Join(texts, "\n\n") + "\n"
+	if *output == "" {
+		fmt.Print(report)
+	} else {
+		if err := os.WriteFile(*output, []byte(report), 0o644); err != nil {
+			return fmt.Errorf("не удалось записать отчет: %w", err)
+		}
+		log.Printf("Отчет записан в %s", *output)
+	}
+	if total > 0 {
+		return fmt.Errorf("в тестах найдено ошибок: %d", total)
+	}
+	return nil
+}
+
+// validateTenantTests проверяет тесты одного класса в его хранилище
+func validateTenantTests(ctx context.Context, cfg Config) ([]TestReport, error) {
+	repo, closeRepo, err := openRepository(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRepo()
+	return validateTests(ctx, repo)
+}
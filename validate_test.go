@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newValidationRepository возвращает класс с исправным тестом, тестом с ошибкой в одной строке,
+// тестом без корректных вопросов и описанием несуществующего теста
+func newValidationRepository() *MemoryRepository {
+	repo := NewMemoryRepository()
+	repo.AddTest("Алгебра", [][]interface{}{
+		{"1", "2+2?", "3", "4", "5", "2"},
+		{"2", "3*3?", "6", "9", "", "2"},
+	})
+	// Ячейки с процентным форматом: 60% и 25% приходят долями
+	repo.SetTestSettings("Алгебра", [][]interface{}{{"pass_percent", 0.6}, {"wrong_penalty", 0.25}})
+	repo.AddTest("Геометрия", [][]interface{}{
+		{"1", "Сумма углов треугольника?", "90", "180", "360", "2"},
+		{"2", "Сколько сторон у квадрата?", "3", "4", "", "5"},
+	})
+	repo.SetTestSettings("Геометрия", [][]interface{}{{"pass_percent", "70%"}})
+	repo.AddTest("Черновик", [][]interface{}{
+		{"1", "", "а", "б", "", "1"},
+	})
+	repo.SetTestCatalog([][]interface{}{
+		{"Алгебра"},
+		{"Геометрия"},
+		{"Черновик"},
+		{"Химия"},
+	})
+	return repo
+}
+
+func TestValidationReport(t *testing.T) {
+	reports, err := validateTests(context.Background(), newValidationRepository())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := countProblems(reports); got != 4 {
+		t.Errorf("ошибок %d, ожидалось 4", got)
+	}
+
+	want := strings.Join([]string{
+		"🔎 Проверка тестов класса «7А»: тестов — 3, с ошибками — 2.",
+		"",
+		"⚠️ Описания тестов: ошибок — 1",
+		`• Tests!A5: вкладка теста "Химия" не найдена`,
+		"",
+		"✅ Алгебра: вопросов — 2, зачет от 60%, штраф 0.25 веса вопроса",
+		"",
+		"⚠️ Геометрия: вопросов — 1, зачет от 70%, ошибок — 1",
+		`• F3: номер верного ответа должен быть от 1 до 2 (несколько — через запятую), получено "5"`,
+		"",
+		"⚠️ Черновик: вопросов — 0, ошибок — 2",
+		`• B2: не заполнена колонка "вопрос"`,
+		"• в тесте нет ни одного корректного вопроса",
+	}, "\n")
+	if got := validationReportText("7А", reports); got != want {
+		t.Errorf("отчет:\n%s\n\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestValidationReportSingleTest(t *testing.T) {
+	reports, err := validateTests(context.Background(), newValidationRepository(), "Алгебра", "Физика")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Описания тестов проверяются только вместе со всеми тестами; ненайденный тест — ошибка в отчете
+	want := strings.Join([]string{
+		"🔎 Проверка тестов класса «7А»: тестов — 2, с ошибками — 1.",
+		"",
+		"✅ Алгебра: вопросов — 2, зачет от 60%, штраф 0.25 веса вопроса",
+		"",
+		"⚠️ Физика: вопросов — 0, ошибок — 1",
+		"• во вкладке Физика не найдено вопросов",
+	}, "\n")
+	if got := validationReportText("7А", reports); got != want {
+		t.Errorf("отчет:\n%s\n\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestValidateCommand(t *testing.T) {
+	a, r, telegram := newTestApp(t, newValidationRepository())
+	command := func() *tgbotapi.Message {
+		return &tgbotapi.Message{
+			Text:     "/validate Геометрия",
+			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/validate")}},
+		}
+	}
+
+	// Ученику отчет не присылается
+	send(t, r, command())
+	if telegram.sentText("Проверка тестов") {
+		t.Fatal("отчет отправлен ученику")
+	}
+
+	a.tenants.list[0].Admins[testUserID] = true
+	send(t, r, command())
+	if !telegram.sentText("⚠️ Геометрия: вопросов — 1, зачет от 70%, ошибок — 1") {
+		t.Errorf("отчет о тесте не отправлен: %q", telegram.Texts())
+	}
+	if telegram.sentText("Алгебра") {
+		t.Error("в отчет попали тесты, которые не запрашивались")
+	}
+}